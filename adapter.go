@@ -18,8 +18,17 @@ type methodAdapter struct {
 	targetMethodValue reflect.Value
 	requestType       reflect.Type
 	responseType      reflect.Type
+	streaming         streamingKind
 }
 
+// Creates an adapter for the given method, which must have one of the following signatures:
+//
+//	func(context.Context, *RequestStruct) (*ResponseStruct, error)
+//	func(context.Context, *RequestStruct, send func(*ResponseStruct) error) error
+//	func(context.Context, *RequestStruct) (<-chan *ResponseStruct, error)
+//
+// The latter two are streaming methods, producing a stream of responses (see Stream); their response type is the type
+// of the streamed responses.
 func NewAdapter(method interface{}) *methodAdapter {
 	if method == nil {
 		panic(errors.Errorf("nil method provided"))
@@ -27,29 +36,18 @@ func NewAdapter(method interface{}) *methodAdapter {
 
 	t := reflect.TypeOf(method)
 	v := reflect.ValueOf(method)
-
-	expectedSig := "func(context.Context, *<YourRequestStruct>)(*<YourResponseStruct>, error)"
-	foundSig := t.String()
 	if t.Kind() != reflect.Func {
 		panic(errors.Errorf("not a function (%s)", method))
-	} else if t.IsVariadic() || t.NumIn() != 2 {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if !t.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
-		panic(errors.Errorf("wrong signature - must be %s", expectedSig))
-	} else if t.In(1).Kind() != reflect.Ptr {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if t.In(1).Elem().Kind() != reflect.Struct {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if t.NumOut() != 2 {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if t.Out(0).Kind() != reflect.Ptr {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if t.Out(0).Elem().Kind() != reflect.Struct {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if t.Out(1).Kind() != reflect.Interface {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
-	} else if !t.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
-		panic(errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig))
+	}
+
+	switch streaming := streamingKindOf(t); streaming {
+	case streamingSend:
+		return &methodAdapter{method, t, v, t.In(1).Elem(), t.In(2).In(0).Elem(), streaming}
+	case streamingChannel:
+		return &methodAdapter{method, t, v, t.In(1).Elem(), t.Out(0).Elem().Elem(), streaming}
+	}
+	if err := validateMethodType(t); err != nil {
+		panic(err)
 	}
 
 	return &methodAdapter{
@@ -62,6 +60,13 @@ func NewAdapter(method interface{}) *methodAdapter {
 }
 
 func (a *methodAdapter) Call(ctx context.Context, request interface{}) (interface{}, error) {
+	switch a.streaming {
+	case streamingSend:
+		return a.callWithSend(ctx, request), nil
+	case streamingChannel:
+		return a.callWithChannel(ctx, request)
+	}
+
 	// Create a pointer to the service request struct; for example:
 	//  - "request" parameter in this context is MyServiceRequest{...} (it is NOT a pointer!)
 	//  - service method signature is (must be): myService(context.Context, *MyServiceRequest)
@@ -100,3 +105,35 @@ func (a *methodAdapter) RequestType() reflect.Type {
 func (a *methodAdapter) ResponseType() reflect.Type {
 	return a.responseType
 }
+
+func (a *methodAdapter) Streaming() bool {
+	return a.streaming != notStreaming
+}
+
+// Verifies that the given function type matches the signature expected from service methods.
+func validateMethodType(t reflect.Type) error {
+	expectedSig := "func(context.Context, *<YourRequestStruct>)(*<YourResponseStruct>, error)"
+	foundSig := t.String()
+	if t.Kind() != reflect.Func {
+		return errors.Errorf("not a function (%s)", foundSig)
+	} else if t.IsVariadic() || t.NumIn() != 2 {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if !t.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.In(1).Kind() != reflect.Ptr {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.In(1).Elem().Kind() != reflect.Struct {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.NumOut() != 2 {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.Out(0).Kind() != reflect.Ptr {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.Out(0).Elem().Kind() != reflect.Struct {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if t.Out(1).Kind() != reflect.Interface {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	} else if !t.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+		return errors.Errorf("wrong signature - must be %s, found: %s", expectedSig, foundSig)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+type DaemonStatus int
+
+const (
+	DaemonStopped DaemonStatus = iota
+	DaemonStarting
+	DaemonReady
+	DaemonStopping
+	DaemonFailed
+)
+
+func (s DaemonStatus) String() string {
+	switch s {
+	case DaemonStopped:
+		return "stopped"
+	case DaemonStarting:
+		return "starting"
+	case DaemonReady:
+		return "ready"
+	case DaemonStopping:
+		return "stopping"
+	case DaemonFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Daemon is a long-running component of the micro-service, such as a network server.
+type Daemon interface {
+
+	// Name of the daemon, used to identify it in logs.
+	Name() string
+
+	// Runs the daemon, blocking until it stops. Daemons should stop when the given context is cancelled or when Stop is
+	// called, and return nil if they stopped cleanly.
+	Start(ctx context.Context) error
+
+	// Asks the daemon to stop accepting new work, and waits for in-flight work to drain, until the given context is
+	// done. Stopping a daemon that is not running does nothing.
+	Stop(ctx context.Context) error
+
+	// Current status of the daemon.
+	Status() DaemonStatus
+}
+
+// DaemonFunc is a daemon implemented as a function which runs until the given context is cancelled.
+type DaemonFunc func(ctx context.Context) error
+
+type funcDaemon struct {
+	name   string
+	run    DaemonFunc
+	mutex  sync.Mutex
+	status DaemonStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Creates a daemon from the given function. Since functions cannot report readiness, the daemon is considered ready as
+// soon as the function is invoked; stopping the daemon cancels the context given to the function.
+func NewDaemon(name string, run DaemonFunc) Daemon {
+	return &funcDaemon{name: name, run: run}
+}
+
+// Creates a daemon from a function that takes no context, such as daemons written before the Daemon interface existed.
+// Such functions cannot be stopped gracefully: stopping the daemon (or cancelling its context) makes Start return
+// immediately, abandoning the function, which keeps running in the background until it returns or the process exits.
+// Panics raised by the function are returned as errors.
+func DaemonFromFunc(name string, run func() error) Daemon {
+	return NewDaemon(name, func(ctx context.Context) error {
+		result := make(chan error, 1)
+		go func() {
+			defer func() {
+				if rvr := recover(); rvr != nil {
+					result <- errors.Errorf("daemon panicked: %v", rvr)
+				}
+			}()
+			result <- run()
+		}()
+		select {
+		case err := <-result:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	})
+}
+
+func (d *funcDaemon) Name() string {
+	return d.name
+}
+
+func (d *funcDaemon) Start(ctx context.Context) (err error) {
+	d.mutex.Lock()
+	if d.done != nil {
+		d.mutex.Unlock()
+		return errors.Errorf("daemon '%s' already started", d.name)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	d.cancel, d.done, d.status = cancel, done, DaemonReady
+	d.mutex.Unlock()
+
+	// Reset state even if the function panics, so the daemon can be restarted
+	returned := false
+	defer func() {
+		cancel()
+		d.mutex.Lock()
+		d.cancel, d.done = nil, nil
+		if err != nil || !returned {
+			d.status = DaemonFailed
+		} else {
+			d.status = DaemonStopped
+		}
+		d.mutex.Unlock()
+		close(done)
+	}()
+
+	err = d.run(ctx)
+	returned = true
+	return err
+}
+
+func (d *funcDaemon) Stop(ctx context.Context) error {
+	d.mutex.Lock()
+	cancel, done := d.cancel, d.done
+	if done == nil {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.status = DaemonStopping
+	d.mutex.Unlock()
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "daemon '%s' did not stop in time", d.name)
+	}
+}
+
+func (d *funcDaemon) Status() DaemonStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status
+}
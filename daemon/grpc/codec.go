@@ -0,0 +1,29 @@
+package grpc
+
+import (
+	"encoding/json"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name of the codec used to encode requests & responses of methods served by reflection (ie. without generated code);
+// clients must use it as their content sub-type (eg. via grpc.CallContentSubtype).
+const JSONCodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// Encodes messages as JSON, using their JSON field names.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return JSONCodecName
+}
@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// gRPC status codes of HTTP status codes; other 4xx statuses map to FailedPrecondition, and other 5xx statuses map to
+// Internal.
+var httpStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusMethodNotAllowed:    codes.Unimplemented,
+	http.StatusRequestTimeout:      codes.DeadlineExceeded,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	499:                            codes.Canceled, // client closed request
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// Returns the gRPC status code of the given HTTP status code.
+func codeOf(httpStatus int) codes.Code {
+	if code, ok := httpStatusCodes[httpStatus]; ok {
+		return code
+	} else if httpStatus >= 400 && httpStatus < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}
+
+// Maps the given method error to a gRPC status error:
+//
+//   - gRPC status errors are returned as is
+//   - HTTP errors (see httpd.NewHttpError) get the gRPC code of their HTTP status, and their cause as the message
+//   - cancelled or timed-out contexts get the Canceled & DeadlineExceeded codes
+//   - other errors are internal errors, whose message is not exposed to clients
+func statusError(err error) error {
+	for cause := err; cause != nil; {
+		if _, ok := status.FromError(cause); ok {
+			return cause
+		} else if httpErr, ok := cause.(httpd.ErrHttp); ok {
+			message := http.StatusText(httpErr.Code())
+			if httpErr.Cause() != nil {
+				message = httpErr.Cause().Error()
+			}
+			return status.Error(codeOf(httpErr.Code()), message)
+		} else if cause == context.Canceled {
+			return status.Error(codes.Canceled, cause.Error())
+		} else if cause == context.DeadlineExceeded {
+			return status.Error(codes.DeadlineExceeded, cause.Error())
+		}
+
+		// Unwrap errors (HTTP errors have causes too, so errors.Cause does not suffice)
+		causer, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = causer.Cause()
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,44 @@
+package grpc
+
+import (
+	"context"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"testing"
+)
+
+func TestStatusError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err     error
+		code    codes.Code
+		message string
+	}{
+		"status":            {errors.Wrap(status.Error(codes.Aborted, "aborted"), "failed"), codes.Aborted, "aborted"},
+		"bad_request":       {httpd.NewHttpError(http.StatusBadRequest, errors.New("bad")), codes.InvalidArgument, "bad"},
+		"unprocessable":     {httpd.NewHttpError(http.StatusUnprocessableEntity, errors.New("bad")), codes.InvalidArgument, "bad"},
+		"unauthorized":      {httpd.NewHttpError(http.StatusUnauthorized, nil), codes.Unauthenticated, "Unauthorized"},
+		"forbidden":         {httpd.NewHttpError(http.StatusForbidden, nil), codes.PermissionDenied, "Forbidden"},
+		"not_found":         {httpd.NewHttpError(http.StatusNotFound, nil), codes.NotFound, "Not Found"},
+		"conflict":          {httpd.NewHttpError(http.StatusConflict, nil), codes.AlreadyExists, "Conflict"},
+		"too_many_requests": {httpd.NewHttpError(http.StatusTooManyRequests, nil), codes.ResourceExhausted, "Too Many Requests"},
+		"other_4xx":         {httpd.NewHttpError(http.StatusGone, nil), codes.FailedPrecondition, "Gone"},
+		"not_implemented":   {httpd.NewHttpError(http.StatusNotImplemented, nil), codes.Unimplemented, "Not Implemented"},
+		"unavailable":       {httpd.NewHttpError(http.StatusServiceUnavailable, nil), codes.Unavailable, "Service Unavailable"},
+		"other_5xx":         {httpd.NewHttpError(http.StatusBadGateway, nil), codes.Internal, "Bad Gateway"},
+		"wrapped_http":      {errors.Wrap(httpd.NewHttpError(http.StatusNotFound, errors.New("missing")), "failed"), codes.NotFound, "missing"},
+		"canceled":          {errors.Wrap(context.Canceled, "failed"), codes.Canceled, "context canceled"},
+		"deadline":          {context.DeadlineExceeded, codes.DeadlineExceeded, "context deadline exceeded"},
+		"other":             {errors.New("secret"), codes.Internal, "internal error"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, ok := status.FromError(statusError(tc.err))
+			require.True(t, ok)
+			require.Equal(t, tc.code, s.Code())
+			require.Equal(t, tc.message, s.Message())
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/pkg/errors"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Go type generated (by protoc-gen-go) for a proto message.
+type goType struct {
+	importPath string
+	pkg        string
+	name       string
+}
+
+// Generates the msvc bindings of all services in the files to generate.
+func generate(request *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	types := make(map[string]goType)
+	files := make(map[string]*descriptor.FileDescriptorProto)
+	for _, file := range request.ProtoFile {
+		files[file.GetName()] = file
+		importPath, pkg := goPackageOf(file)
+		prefix := "."
+		if file.GetPackage() != "" {
+			prefix += file.GetPackage() + "."
+		}
+		addMessageTypes(types, importPath, pkg, prefix, nil, file.MessageType)
+	}
+
+	response := &plugin.CodeGeneratorResponse{}
+	for _, name := range request.FileToGenerate {
+		file, ok := files[name]
+		if !ok {
+			response.Error = proto.String(fmt.Sprintf("missing descriptor of '%s'", name))
+			return response
+		} else if len(file.Service) == 0 {
+			continue
+		}
+		content, err := generateFile(file, types)
+		if err != nil {
+			response.Error = proto.String(err.Error())
+			return response
+		}
+		response.File = append(response.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(strings.TrimSuffix(name, path.Ext(name)) + "_msvc.pb.go"),
+			Content: proto.String(content),
+		})
+	}
+	return response
+}
+
+// Returns the Go import path & package name of the given file, using the same rules as protoc-gen-go: the
+// "go_package" option if given, otherwise the file's directory and proto package.
+func goPackageOf(file *descriptor.FileDescriptorProto) (string, string) {
+	importPath, pkg := path.Dir(file.GetName()), ""
+	if goPackage := file.GetOptions().GetGoPackage(); goPackage != "" {
+		if i := strings.LastIndex(goPackage, ";"); i >= 0 {
+			importPath, pkg = goPackage[:i], goPackage[i+1:]
+		} else {
+			importPath, pkg = goPackage, path.Base(goPackage)
+		}
+	}
+	if pkg == "" {
+		if file.GetPackage() != "" {
+			pkg = file.GetPackage()
+		} else {
+			pkg = strings.TrimSuffix(path.Base(file.GetName()), path.Ext(file.GetName()))
+		}
+	}
+	return importPath, strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, pkg)
+}
+
+// Adds the Go types of the given messages (and their nested messages) to the given map, by their fully-qualified proto
+// names.
+func addMessageTypes(types map[string]goType, importPath, pkg, prefix string, parents []string, messages []*descriptor.DescriptorProto) {
+	for _, message := range messages {
+		names := append(append([]string{}, parents...), message.GetName())
+		types[prefix+message.GetName()] = goType{importPath, pkg, generator.CamelCaseSlice(names)}
+		addMessageTypes(types, importPath, pkg, prefix+message.GetName()+".", names, message.NestedType)
+	}
+}
+
+// Generates the source of the msvc bindings of the services of the given file.
+func generateFile(file *descriptor.FileDescriptorProto, types map[string]goType) (string, error) {
+	importPath, pkg := goPackageOf(file)
+	imports := map[string]string{
+		"context":                              "context",
+		"github.com/arikkfir/msvc":             "msvc",
+		"github.com/arikkfir/msvc/daemon/grpc": "msvcgrpc",
+		"google.golang.org/grpc":               "grpc",
+	}
+
+	// Returns the (qualified, if needed) Go type name of the given proto message name
+	typeName := func(name string) (string, error) {
+		t, ok := types[name]
+		if !ok {
+			return "", errors.Errorf("unknown message type '%s'", name)
+		} else if t.importPath == importPath {
+			return t.name, nil
+		}
+		imports[t.importPath] = t.pkg
+		return t.pkg + "." + t.name, nil
+	}
+
+	body := &bytes.Buffer{}
+	for _, service := range file.Service {
+		serviceName := generator.CamelCase(service.GetName())
+		serverType := "msvc" + serviceName + "Server"
+		fmt.Fprintf(body, "// Registers the %s service on the given gRPC server, serving its RPCs by the methods of the given\n", serviceName)
+		fmt.Fprintf(body, "// micro-service of the same names.\n")
+		fmt.Fprintf(body, "func Register%sMethods(s *grpc.Server, ms *msvc.MicroService) {\n", serviceName)
+		fmt.Fprintf(body, "\tRegister%sServer(s, &%s{ms})\n", serviceName, serverType)
+		fmt.Fprintf(body, "}\n\n")
+		fmt.Fprintf(body, "type %s struct {\n\tms *msvc.MicroService\n}\n", serverType)
+
+		for _, method := range service.Method {
+			methodName := generator.CamelCase(method.GetName())
+			in, err := typeName(method.GetInputType())
+			if err != nil {
+				return "", err
+			}
+			out, err := typeName(method.GetOutputType())
+			if err != nil {
+				return "", err
+			}
+
+			fmt.Fprintf(body, "\n")
+			switch {
+			case method.GetClientStreaming():
+				imports["google.golang.org/grpc/codes"] = "codes"
+				imports["google.golang.org/grpc/status"] = "status"
+				fmt.Fprintf(body, "func (s *%s) %s(stream %s_%sServer) error {\n", serverType, methodName, serviceName, methodName)
+				fmt.Fprintf(body, "\treturn status.Error(codes.Unimplemented, %q)\n", "client streaming is not supported by msvc methods")
+				fmt.Fprintf(body, "}\n")
+			case method.GetServerStreaming():
+				fmt.Fprintf(body, "func (s *%s) %s(in *%s, stream %s_%sServer) error {\n", serverType, methodName, in, serviceName, methodName)
+				fmt.Fprintf(body, "\tnewOut := func() interface{} { return &%s{} }\n", out)
+				fmt.Fprintf(body, "\tsend := func(out interface{}) error { return stream.Send(out.(*%s)) }\n", out)
+				fmt.Fprintf(body, "\treturn msvcgrpc.InvokeStream(stream.Context(), s.ms, %q, in, newOut, send)\n", method.GetName())
+				fmt.Fprintf(body, "}\n")
+			default:
+				fmt.Fprintf(body, "func (s *%s) %s(ctx context.Context, in *%s) (*%s, error) {\n", serverType, methodName, in, out)
+				fmt.Fprintf(body, "\tout := &%s{}\n", out)
+				fmt.Fprintf(body, "\tif err := msvcgrpc.Invoke(ctx, s.ms, %q, in, out); err != nil {\n", method.GetName())
+				fmt.Fprintf(body, "\t\treturn nil, err\n\t}\n\treturn out, nil\n")
+				fmt.Fprintf(body, "}\n")
+			}
+		}
+		fmt.Fprintf(body, "\n")
+	}
+
+	importPaths := make([]string, 0, len(imports))
+	for importPath := range imports {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+
+	source := &bytes.Buffer{}
+	fmt.Fprintf(source, "// Code generated by protoc-gen-msvc. DO NOT EDIT.\n// source: %s\n\n", file.GetName())
+	fmt.Fprintf(source, "package %s\n\nimport (\n", pkg)
+	for _, importPath := range importPaths {
+		fmt.Fprintf(source, "\t%s %q\n", imports[importPath], importPath)
+	}
+	fmt.Fprintf(source, ")\n\n")
+	source.Write(body.Bytes())
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed formatting generated source of '%s'", file.GetName())
+	}
+	return string(formatted), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	wrappers := &descriptor.FileDescriptorProto{
+		Name:        proto.String("google/protobuf/wrappers.proto"),
+		Package:     proto.String("google.protobuf"),
+		Options:     &descriptor.FileOptions{GoPackage: proto.String("github.com/golang/protobuf/ptypes/wrappers")},
+		MessageType: []*descriptor.DescriptorProto{{Name: proto.String("Int32Value")}},
+	}
+	greeter := &descriptor.FileDescriptorProto{
+		Name:       proto.String("acme/greeter.proto"),
+		Package:    proto.String("acme.greeter"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		Options:    &descriptor.FileOptions{GoPackage: proto.String("github.com/acme/greeter;greeter")},
+		MessageType: []*descriptor.DescriptorProto{
+			{Name: proto.String("GreetRequest"), NestedType: []*descriptor.DescriptorProto{{Name: proto.String("NamePart")}}},
+			{Name: proto.String("GreetResponse")},
+		},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptor.MethodDescriptorProto{
+				{Name: proto.String("Greet"), InputType: proto.String(".acme.greeter.GreetRequest"), OutputType: proto.String(".acme.greeter.GreetResponse")},
+				{Name: proto.String("Count"), InputType: proto.String(".acme.greeter.GreetRequest.NamePart"), OutputType: proto.String(".google.protobuf.Int32Value"), ServerStreaming: proto.Bool(true)},
+				{Name: proto.String("Chat"), InputType: proto.String(".acme.greeter.GreetRequest"), OutputType: proto.String(".acme.greeter.GreetResponse"), ClientStreaming: proto.Bool(true), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}
+
+	t.Run("services", func(t *testing.T) {
+		response := generate(&plugin.CodeGeneratorRequest{
+			FileToGenerate: []string{"acme/greeter.proto"},
+			ProtoFile:      []*descriptor.FileDescriptorProto{wrappers, greeter},
+		})
+		require.Nil(t, response.Error)
+		require.Len(t, response.File, 1)
+		require.Equal(t, "acme/greeter_msvc.pb.go", response.File[0].GetName())
+		require.Equal(t, `// Code generated by protoc-gen-msvc. DO NOT EDIT.
+// source: acme/greeter.proto
+
+package greeter
+
+import (
+	context "context"
+	msvc "github.com/arikkfir/msvc"
+	msvcgrpc "github.com/arikkfir/msvc/daemon/grpc"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// Registers the Greeter service on the given gRPC server, serving its RPCs by the methods of the given
+// micro-service of the same names.
+func RegisterGreeterMethods(s *grpc.Server, ms *msvc.MicroService) {
+	RegisterGreeterServer(s, &msvcGreeterServer{ms})
+}
+
+type msvcGreeterServer struct {
+	ms *msvc.MicroService
+}
+
+func (s *msvcGreeterServer) Greet(ctx context.Context, in *GreetRequest) (*GreetResponse, error) {
+	out := &GreetResponse{}
+	if err := msvcgrpc.Invoke(ctx, s.ms, "Greet", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *msvcGreeterServer) Count(in *GreetRequest_NamePart, stream Greeter_CountServer) error {
+	newOut := func() interface{} { return &wrappers.Int32Value{} }
+	send := func(out interface{}) error { return stream.Send(out.(*wrappers.Int32Value)) }
+	return msvcgrpc.InvokeStream(stream.Context(), s.ms, "Count", in, newOut, send)
+}
+
+func (s *msvcGreeterServer) Chat(stream Greeter_ChatServer) error {
+	return status.Error(codes.Unimplemented, "client streaming is not supported by msvc methods")
+}
+`, response.File[0].GetContent())
+	})
+	t.Run("no_services", func(t *testing.T) {
+		response := generate(&plugin.CodeGeneratorRequest{
+			FileToGenerate: []string{"google/protobuf/wrappers.proto"},
+			ProtoFile:      []*descriptor.FileDescriptorProto{wrappers},
+		})
+		require.Nil(t, response.Error)
+		require.Len(t, response.File, 0)
+	})
+	t.Run("unknown_type", func(t *testing.T) {
+		response := generate(&plugin.CodeGeneratorRequest{
+			FileToGenerate: []string{"acme/greeter.proto"},
+			ProtoFile:      []*descriptor.FileDescriptorProto{greeter},
+		})
+		require.Equal(t, "unknown message type '.google.protobuf.Int32Value'", response.GetError())
+	})
+}
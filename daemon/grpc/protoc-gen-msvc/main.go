@@ -0,0 +1,41 @@
+// Command protoc-gen-msvc is a protoc plugin generating gRPC bindings of micro-service methods: for each service in the
+// given ".proto" files, it generates a Register<Service>Methods function that registers the service (as generated by
+// protoc-gen-go's gRPC plugin) on a gRPC server, serving each RPC by the micro-service method of the same name. Use it
+// alongside protoc-gen-go:
+//
+//	protoc --go_out=plugins=grpc:. --msvc_out=. greeter.proto
+//
+// Requests & responses are converted between proto messages and method structs by their JSON field names. Unary and
+// server-streaming RPCs are supported; client-streaming RPCs respond with the Unimplemented code.
+package main
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-msvc: failed reading input: %v\n", err)
+		os.Exit(1)
+	}
+	request := &plugin.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(input, request); err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-msvc: failed parsing input: %v\n", err)
+		os.Exit(1)
+	}
+
+	output, err := proto.Marshal(generate(request))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-msvc: failed encoding output: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stdout.Write(output); err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-msvc: failed writing output: %v\n", err)
+		os.Exit(1)
+	}
+}
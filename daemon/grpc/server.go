@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"net"
+	"sync"
+)
+
+type Config struct {
+	Port uint16
+
+	// Name of the gRPC service (including its package, eg. "acme.Greeter") under which methods are served by reflection;
+	// defaults to the micro-service name.
+	Service string
+}
+
+type serverDaemon struct {
+	ms       *msvc.MicroService
+	addr     string
+	listener net.Listener
+	service  *reflectionService
+	register []func(*grpc.Server)
+	options  []grpc.ServerOption
+	mutex    sync.Mutex
+	status   msvc.DaemonStatus
+	server   *grpc.Server
+}
+
+type ServerOption func(*serverDaemon)
+
+// Registers services on the gRPC server, typically the functions generated by protoc-gen-msvc (eg. RegisterXXXMethods)
+// bound to the micro-service. Calls to registered services are served by them, rather than by reflection.
+func WithServices(register ...func(*grpc.Server)) ServerOption {
+	return func(d *serverDaemon) {
+		d.register = append(d.register, register...)
+	}
+}
+
+// Adds options to the underlying gRPC server (eg. credentials or interceptors).
+func WithServerOptions(options ...grpc.ServerOption) ServerOption {
+	return func(d *serverDaemon) {
+		d.options = append(d.options, options...)
+	}
+}
+
+// Serves on the given listener instead of listening on the configured port (eg. a bufconn listener in tests). Since
+// listeners are closed when the daemon stops, such daemons cannot be restarted.
+func WithListener(listener net.Listener) ServerOption {
+	return func(d *serverDaemon) {
+		d.listener = listener
+	}
+}
+
+// Creates the gRPC server daemon, serving all methods of the given micro-service by reflection: a call to
+// "/<service>/<method>" invokes the method of that name through its method chain (so all middleware applies), with
+// requests & responses encoded by the client's codec (see JSONCodecName). Services registered via WithServices (eg.
+// generated from ".proto" files by protoc-gen-msvc) are served by their own implementation.
+func NewGRPCServer(ms *msvc.MicroService, config *Config, options ...ServerOption) msvc.Daemon {
+	service := config.Service
+	if service == "" {
+		service = ms.Name()
+	}
+	d := &serverDaemon{
+		ms:      ms,
+		addr:    fmt.Sprintf(":%d", config.Port),
+		service: &reflectionService{ms: ms, service: service},
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+func (d *serverDaemon) Name() string {
+	return "grpc"
+}
+
+func (d *serverDaemon) Start(ctx context.Context) error {
+	d.mutex.Lock()
+	if d.server != nil {
+		d.mutex.Unlock()
+		return errors.New("daemon 'grpc' already started")
+	}
+	d.status = msvc.DaemonStarting
+	d.mutex.Unlock()
+
+	listener := d.listener
+	if listener == nil {
+		var err error
+		if listener, err = net.Listen("tcp", d.addr); err != nil {
+			d.setStatus(msvc.DaemonFailed)
+			return errors.Wrapf(err, "failed listening on '%s'", d.addr)
+		}
+	}
+
+	options := append([]grpc.ServerOption{grpc.UnknownServiceHandler(d.service.handle)}, d.options...)
+	server := grpc.NewServer(options...)
+	for _, register := range d.register {
+		register(server)
+	}
+	d.mutex.Lock()
+	d.server, d.status = server, msvc.DaemonReady
+	d.mutex.Unlock()
+
+	// Stop the server when the context is cancelled
+	served := make(chan struct{})
+	defer close(served)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), d.ms.ShutdownTimeout())
+			defer cancel()
+			_ = d.Stop(shutdownCtx)
+		case <-served:
+		}
+	}()
+
+	err := server.Serve(listener)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.server = nil
+	if err != nil {
+		d.status = msvc.DaemonFailed
+		return errors.Wrap(err, "failed serving gRPC")
+	}
+	d.status = msvc.DaemonStopped
+	return nil
+}
+
+// Stops accepting new calls and waits for in-flight calls to complete; if the given context is done first, in-flight
+// calls are cancelled.
+func (d *serverDaemon) Stop(ctx context.Context) error {
+	d.mutex.Lock()
+	server := d.server
+	if server == nil {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.status = msvc.DaemonStopping
+	d.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+		return errors.Wrap(ctx.Err(), "failed draining gRPC server")
+	}
+}
+
+func (d *serverDaemon) Status() msvc.DaemonStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status
+}
+
+func (d *serverDaemon) setStatus(status msvc.DaemonStatus) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.status = status
+}
@@ -0,0 +1,225 @@
+package grpc
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type greetRequest struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+type countEvent struct {
+	N int `json:"n"`
+}
+
+type echoRequest struct {
+	Value string `json:"value,omitempty"`
+}
+
+func newTestMicroService(t *testing.T) *msvc.MicroService {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("Greet", func(ctx context.Context, req *greetRequest) (*greetResponse, error) {
+		switch req.Name {
+		case "":
+			return nil, httpd.NewHttpError(http.StatusBadRequest, errors.New("name is required"))
+		case "nobody":
+			return nil, httpd.NewHttpError(http.StatusNotFound, errors.New("nobody not found"))
+		case "secret":
+			return nil, errors.New("secret failure")
+		case "panic":
+			panic("bad")
+		}
+		title := req.Title
+		if title == "" {
+			title = MetadataValue(ctx, "x-title")
+		}
+		return &greetResponse{Greeting: strings.Join(strings.Fields("Hello "+title+" "+req.Name), " ")}, nil
+	})
+	ms.AddMethod("Count", func(ctx context.Context, req *struct{}, send func(*countEvent) error) error {
+		for i := 1; i <= 2; i++ {
+			if err := send(&countEvent{i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	ms.AddMethod("Echo", func(ctx context.Context, req *echoRequest) (*echoRequest, error) {
+		return &echoRequest{Value: strings.ToUpper(req.Value)}, nil
+	})
+	ms.AddMiddleware(func(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := method(ctx, request)
+			if greeting, ok := response.(*greetResponse); ok {
+				greeting.Greeting += "!"
+			}
+			return response, err
+		}
+	})
+	return ms
+}
+
+// A service implemented the way protoc-gen-msvc generates them, for a service such as:
+//
+//	service Echoer {
+//	  rpc Echo (google.protobuf.StringValue) returns (google.protobuf.StringValue);
+//	}
+type echoerServer interface {
+	Echo(context.Context, *wrappers.StringValue) (*wrappers.StringValue, error)
+}
+
+type msvcEchoerServer struct {
+	ms *msvc.MicroService
+}
+
+func (s *msvcEchoerServer) Echo(ctx context.Context, in *wrappers.StringValue) (*wrappers.StringValue, error) {
+	out := &wrappers.StringValue{}
+	if err := Invoke(ctx, s.ms, "Echo", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+var echoerServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echoer",
+	HandlerType: (*echoerServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Echo",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &wrappers.StringValue{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(echoerServer).Echo(ctx, in)
+		},
+	}},
+}
+
+// Starts a gRPC daemon over an in-memory listener, returning a client connection to it.
+func startServer(t *testing.T, ms *msvc.MicroService, config *Config, options ...ServerOption) (*grpc.ClientConn, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	daemon := NewGRPCServer(ms, config, append(options, WithListener(listener))...)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- daemon.Start(ctx) }()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return listener.Dial() }))
+	require.NoError(t, err)
+	return conn, func() {
+		conn.Close()
+		cancel()
+		select {
+		case err := <-stopped:
+			require.NoError(t, err)
+			require.Equal(t, msvc.DaemonStopped, daemon.Status())
+		case <-time.After(5 * time.Second):
+			t.Fatal("daemon did not stop")
+		}
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	ms := newTestMicroService(t)
+	conn, stop := startServer(t, ms, &Config{}, WithServices(func(s *grpc.Server) {
+		s.RegisterService(&echoerServiceDesc, &msvcEchoerServer{ms})
+	}))
+	defer stop()
+	ctx := context.Background()
+	json := grpc.CallContentSubtype(JSONCodecName)
+
+	t.Run("unary", func(t *testing.T) {
+		response := &greetResponse{}
+		require.NoError(t, conn.Invoke(ctx, "/test/Greet", &greetRequest{Name: "Jack", Title: "Mr."}, response, json))
+		require.Equal(t, "Hello Mr. Jack!", response.Greeting)
+	})
+	t.Run("metadata", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(ctx, "X-Title", "Dr.")
+		response := &greetResponse{}
+		require.NoError(t, conn.Invoke(ctx, "/test/Greet", &greetRequest{Name: "Jack"}, response, json))
+		require.Equal(t, "Hello Dr. Jack!", response.Greeting)
+	})
+	t.Run("streaming", func(t *testing.T) {
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/test/Count", json)
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMsg(&struct{}{}))
+		require.NoError(t, stream.CloseSend())
+		events := make([]int, 0)
+		for {
+			event := &countEvent{}
+			if err := stream.RecvMsg(event); err == io.EOF {
+				break
+			} else {
+				require.NoError(t, err)
+			}
+			events = append(events, event.N)
+		}
+		require.Equal(t, []int{1, 2}, events)
+	})
+	t.Run("generated", func(t *testing.T) {
+		response := &wrappers.StringValue{}
+		require.NoError(t, conn.Invoke(ctx, "/test.Echoer/Echo", &wrappers.StringValue{Value: "hi"}, response))
+		require.Equal(t, "HI", response.Value)
+	})
+	t.Run("errors", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			method  string
+			request interface{}
+			code    codes.Code
+			message string
+		}{
+			"bad_request":    {"/test/Greet", &greetRequest{}, codes.InvalidArgument, "name is required"},
+			"not_found":      {"/test/Greet", &greetRequest{Name: "nobody"}, codes.NotFound, "nobody not found"},
+			"internal":       {"/test/Greet", &greetRequest{Name: "secret"}, codes.Internal, "internal error"},
+			"panic":          {"/test/Greet", &greetRequest{Name: "panic"}, codes.Internal, "internal error"},
+			"unknown_field":  {"/test/Greet", &countEvent{1}, codes.InvalidArgument, ""},
+			"unknown_method": {"/test/Unknown", &struct{}{}, codes.Unimplemented, "unknown method Unknown for service test"},
+			"unknown_svc":    {"/other/Greet", &struct{}{}, codes.Unimplemented, "unknown service other"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				err := conn.Invoke(ctx, tc.method, tc.request, &greetResponse{}, json)
+				require.Error(t, err)
+				s, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.code, s.Code())
+				if tc.message != "" {
+					require.Equal(t, tc.message, s.Message())
+				}
+			})
+		}
+	})
+}
+
+func TestGRPCServerService(t *testing.T) {
+	conn, stop := startServer(t, newTestMicroService(t), &Config{Service: "acme.Greeter"})
+	defer stop()
+
+	response := &greetResponse{}
+	err := conn.Invoke(context.Background(), "/acme.Greeter/Greet", &greetRequest{Name: "Jill"}, response, grpc.CallContentSubtype(JSONCodecName))
+	require.NoError(t, err)
+	require.Equal(t, "Hello Jill!", response.Greeting)
+
+	err = conn.Invoke(context.Background(), "/test/Greet", &greetRequest{Name: "Jill"}, response, grpc.CallContentSubtype(JSONCodecName))
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
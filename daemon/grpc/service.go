@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"reflect"
+	"strings"
+)
+
+// Returns the metadata sent by the client of the current call (an empty set if none).
+func Metadata(ctx context.Context) metadata.MD {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return md
+	}
+	return metadata.MD{}
+}
+
+// Returns the first value of the given metadata key sent by the client of the current call, or "" if missing. Keys are
+// case-insensitive.
+func MetadataValue(ctx context.Context, key string) string {
+	if values := Metadata(ctx).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Serves calls of services that have no registered (generated) implementation, mapping them to micro-service methods by
+// name: "/<service>/<method>" calls the method, if the service is the one the daemon serves. Requests & responses are
+// encoded as JSON, so clients must use the JSON codec (see JSONCodecName).
+type reflectionService struct {
+	ms      *msvc.MicroService
+	service string
+}
+
+func (s *reflectionService) handle(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "missing method name")
+	}
+	service, name := splitMethod(fullMethod)
+	if service != s.service {
+		return status.Errorf(codes.Unimplemented, "unknown service %s", service)
+	}
+	method, adapter := s.ms.GetMethod(name), s.ms.GetMethodAdapter(name)
+	if method == nil || adapter == nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s for service %s", name, service)
+	}
+
+	// Receive the raw request, and decode it here (rather than by the codec) so invalid requests are reported as such
+	var raw json.RawMessage
+	if err := stream.RecvMsg(&raw); err != nil {
+		return err
+	}
+	request := reflect.New(adapter.RequestType())
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(request.Interface()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed decoding request: %v", err)
+	}
+
+	ctx := msvc.SetInContext(stream.Context(), s.ms)
+	response, err := call(ctx, name, method, request.Elem().Interface())
+	if err != nil {
+		s.ms.Log("method", name, "err", err)
+		return statusError(err)
+	}
+	if responses, ok := response.(msvc.Stream); ok {
+		err = sendAll(ctx, responses, stream.SendMsg)
+	} else {
+		err = stream.SendMsg(response)
+	}
+	if err != nil {
+		s.ms.Log("method", name, "err", err)
+		return statusError(err)
+	}
+	return nil
+}
+
+// Splits the given full method name ("/<service>/<method>") into its service & method names.
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+// Invokes the given method chain, recovering from panics.
+func call(ctx context.Context, name string, method msvc.Method, request interface{}) (response interface{}, err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			response, err = nil, errors.Errorf("method '%s' panicked: %v", name, rvr)
+		}
+	}()
+	return method(ctx, request)
+}
+
+// Sends all responses of the given stream, closing it once done.
+func sendAll(ctx context.Context, stream msvc.Stream, send func(interface{}) error) error {
+	defer stream.Close()
+	for {
+		response, ok, err := stream.Next(ctx)
+		if err != nil {
+			return err
+		} else if !ok {
+			return nil
+		} else if err := send(response); err != nil {
+			return errors.Wrap(err, "failed sending streamed response")
+		}
+	}
+}
+
+// Invokes the named method of the given micro-service with the given request message, converting it to the request
+// struct of the method (and the method's response back to the given response message) by JSON field names; all
+// middleware of the method applies. Used by code generated by protoc-gen-msvc for unary RPCs.
+func Invoke(ctx context.Context, ms *msvc.MicroService, name string, in, out interface{}) error {
+	method, adapter := ms.GetMethod(name), ms.GetMethodAdapter(name)
+	if method == nil || adapter == nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", name)
+	}
+	request, err := convertRequest(in, adapter.RequestType())
+	if err != nil {
+		return err
+	}
+
+	ctx = msvc.SetInContext(ctx, ms)
+	response, err := call(ctx, name, method, request)
+	if err == nil {
+		if responses, ok := response.(msvc.Stream); ok {
+			responses.Close()
+			err = errors.Errorf("method '%s' is streaming", name)
+		} else {
+			err = convert(response, out)
+		}
+	}
+	if err != nil {
+		ms.Log("method", name, "err", err)
+		return statusError(err)
+	}
+	return nil
+}
+
+// Invokes the named method of the given micro-service like Invoke, sending each of its streamed responses (converted
+// to a new message created by newOut) via the given send function. Non-streaming methods send a single response. Used
+// by code generated by protoc-gen-msvc for server-streaming RPCs.
+func InvokeStream(ctx context.Context, ms *msvc.MicroService, name string, in interface{}, newOut func() interface{}, send func(interface{}) error) error {
+	method, adapter := ms.GetMethod(name), ms.GetMethodAdapter(name)
+	if method == nil || adapter == nil {
+		return status.Errorf(codes.Unimplemented, "unknown method %s", name)
+	}
+	request, err := convertRequest(in, adapter.RequestType())
+	if err != nil {
+		return err
+	}
+
+	sendConverted := func(response interface{}) error {
+		out := newOut()
+		if err := convert(response, out); err != nil {
+			return err
+		}
+		return send(out)
+	}
+	ctx = msvc.SetInContext(ctx, ms)
+	response, err := call(ctx, name, method, request)
+	if err == nil {
+		if responses, ok := response.(msvc.Stream); ok {
+			err = sendAll(ctx, responses, sendConverted)
+		} else {
+			err = sendConverted(response)
+		}
+	}
+	if err != nil {
+		ms.Log("method", name, "err", err)
+		return statusError(err)
+	}
+	return nil
+}
+
+// Converts the given request message to a value of the given request struct type.
+func convertRequest(in interface{}, requestType reflect.Type) (interface{}, error) {
+	request := reflect.New(requestType)
+	if err := convert(in, request.Interface()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed converting request: %v", err)
+	}
+	return request.Elem().Interface(), nil
+}
+
+// Converts the given value to the given target (a pointer) by encoding it to JSON and decoding it back.
+func convert(from, to interface{}) error {
+	encoded, err := json.Marshal(from)
+	if err != nil {
+		return errors.Wrap(err, "failed encoding message")
+	} else if err := json.Unmarshal(encoded, to); err != nil {
+		return errors.Wrap(err, "failed decoding message")
+	}
+	return nil
+}
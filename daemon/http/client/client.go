@@ -0,0 +1,329 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Client calls methods of remote micro-services over HTTP. Requests are encoded using the same "http" tags the server
+// uses to decode them, and error statuses are returned as httpd.ErrHttp errors.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+}
+
+type Option func(*Client)
+
+// Uses the given HTTP client to send requests, instead of http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Adds the given header to all requests (eg. for authentication).
+func WithHeader(name, value string) Option {
+	return func(c *Client) {
+		c.headers.Add(name, value)
+	}
+}
+
+// Creates a client for the micro-service at the given base URL (eg. "http://users:3000/v1").
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient, headers: make(http.Header)}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Calls the remote method routed at the given HTTP method & route pattern. The request must be a request struct (or a
+// pointer to one), and the JSON response body, if any, is decoded into the given response pointer (unless it is nil).
+// Error statuses are returned as httpd.ErrHttp errors, after decoding the response body (if any) as well.
+func (c *Client) Call(ctx context.Context, httpMethod, pattern string, request interface{}, response interface{}) error {
+	_, err := c.call(ctx, httpMethod, pattern, request, response)
+	return err
+}
+
+// Calls the remote method, returning whether a response body was decoded into the given response.
+func (c *Client) call(ctx context.Context, httpMethod, pattern string, request interface{}, response interface{}) (bool, error) {
+	requestValue := reflect.ValueOf(request)
+	if requestValue.Kind() == reflect.Ptr {
+		if requestValue.IsNil() {
+			return false, errors.Errorf("nil request provided")
+		}
+		requestValue = requestValue.Elem()
+	}
+	bindings, err := httpd.ParseBindings(requestValue.Type())
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid request type '%s'", requestValue.Type())
+	}
+
+	httpRequest, err := c.newRequest(ctx, httpMethod, pattern, bindings, requestValue)
+	if err != nil {
+		return false, err
+	}
+	httpResponse, err := c.httpClient.Do(httpRequest)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed calling '%s %s'", httpMethod, httpRequest.URL)
+	}
+	defer httpResponse.Body.Close()
+	return decodeResponse(httpResponse, response)
+}
+
+// Binds the given function variable to the remote method routed at the given HTTP method & route pattern, so that
+// calling the function calls the remote method. The function must have the same signature as micro-service methods, for
+// example:
+//
+//	var getUser func(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error)
+//	err := c.Bind(http.MethodGet, "/users/{id}", &getUser)
+func (c *Client) Bind(httpMethod, pattern string, methodPtr interface{}) error {
+	ptrValue := reflect.ValueOf(methodPtr)
+	if ptrValue.Kind() != reflect.Ptr || ptrValue.Elem().Kind() != reflect.Func {
+		return errors.Errorf("expected pointer to function; received '%T'", methodPtr)
+	}
+
+	methodType := ptrValue.Elem().Type()
+	if methodType.NumIn() != 2 || methodType.In(0) != contextType ||
+		methodType.In(1).Kind() != reflect.Ptr || methodType.In(1).Elem().Kind() != reflect.Struct ||
+		methodType.NumOut() != 2 || methodType.Out(0).Kind() != reflect.Ptr || methodType.Out(1) != errorType {
+		return errors.Errorf("wrong signature - must be func(context.Context, *RequestStruct) (*ResponseStruct, error), found: %s", methodType)
+	}
+	if _, err := httpd.ParseBindings(methodType.In(1).Elem()); err != nil {
+		return errors.Wrapf(err, "invalid request type '%s'", methodType.In(1).Elem())
+	}
+
+	responseType := methodType.Out(0)
+	ptrValue.Elem().Set(reflect.MakeFunc(methodType, func(in []reflect.Value) []reflect.Value {
+		ctx := in[0].Interface().(context.Context)
+		responsePtr := reflect.New(responseType.Elem())
+		decoded, err := c.call(ctx, httpMethod, pattern, in[1].Interface(), responsePtr.Interface())
+		if !decoded {
+			responsePtr = reflect.Zero(responseType)
+		}
+		errValue := reflect.Zero(errorType)
+		if err != nil {
+			errValue = reflect.ValueOf(&err).Elem()
+		}
+		return []reflect.Value{responsePtr, errValue}
+	}))
+	return nil
+}
+
+// Encodes the given request struct into an HTTP request, according to its bindings.
+func (c *Client) newRequest(ctx context.Context, httpMethod, pattern string, bindings []httpd.Binding, requestValue reflect.Value) (*http.Request, error) {
+	pathValues := make(map[string]string)
+	query := make(url.Values)
+	headers := make(http.Header)
+	cookies := make([]*http.Cookie, 0)
+	var body io.Reader
+	for _, b := range bindings {
+		fieldValue := requestValue.FieldByIndex(b.Field.Index)
+		if b.Kind == httpd.BindingBody {
+			buffer := new(bytes.Buffer)
+			if err := json.NewEncoder(buffer).Encode(fieldValue.Interface()); err != nil {
+				return nil, errors.Wrapf(err, "failed encoding field '%s'", b.Field.Name)
+			}
+			body = buffer
+			headers.Set("Content-Type", "application/json")
+			continue
+		}
+
+		values := formatValues(fieldValue)
+		if len(values) == 0 {
+			continue
+		}
+		switch b.Kind {
+		case httpd.BindingPath:
+			pathValues[b.Name] = values[0]
+		case httpd.BindingQuery:
+			query[b.Name] = values
+		case httpd.BindingHeader:
+			headers[http.CanonicalHeaderKey(b.Name)] = values
+		case httpd.BindingCookie:
+			cookies = append(cookies, &http.Cookie{Name: b.Name, Value: values[0]})
+		}
+	}
+
+	path, err := httpd.ExpandPattern(pattern, pathValues)
+	if err != nil {
+		return nil, err
+	}
+	requestURL := c.baseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	httpRequest, err := http.NewRequest(httpMethod, requestURL, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed creating request for '%s %s'", httpMethod, requestURL)
+	}
+	httpRequest = httpRequest.WithContext(ctx)
+	for name, values := range c.headers {
+		httpRequest.Header[name] = append([]string{}, values...)
+	}
+	for name, values := range headers {
+		httpRequest.Header[name] = values
+	}
+	for _, cookie := range cookies {
+		httpRequest.AddCookie(cookie)
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	return httpRequest, nil
+}
+
+// Decodes the response body (if any) into the given response (unless nil), returning whether it did, and an ErrHttp for
+// error statuses. Response structs with "http" tags get their status, header & cookie fields populated as well, and
+// have the body decoded into their "body" field.
+func decodeResponse(httpResponse *http.Response, response interface{}) (bool, error) {
+	content, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return false, errors.Wrap(err, "failed reading response")
+	}
+
+	decoded := false
+	var decodeErr error
+	bodyTarget := response
+	if response != nil {
+		if bindings, err := httpd.ParseResponseBindings(reflect.TypeOf(response)); err != nil {
+			return false, errors.Wrapf(err, "invalid response type '%T'", response)
+		} else if bindings != nil {
+			if bodyTarget, decodeErr = injectResponseBindings(httpResponse, bindings, reflect.ValueOf(response).Elem()); decodeErr != nil {
+				decodeErr = errors.Wrap(decodeErr, "failed decoding response")
+			}
+			decoded = true
+		}
+	}
+
+	isJSON := strings.HasPrefix(httpResponse.Header.Get("Content-Type"), "application/json")
+	if bodyTarget != nil && decodeErr == nil && isJSON && len(bytes.TrimSpace(content)) > 0 {
+		if decodeErr = json.Unmarshal(content, bodyTarget); decodeErr == nil {
+			decoded = true
+		} else {
+			decodeErr = errors.Wrap(decodeErr, "failed decoding response")
+		}
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		cause := errors.New(http.StatusText(httpResponse.StatusCode))
+		if !isJSON && len(bytes.TrimSpace(content)) > 0 {
+			cause = errors.New(strings.TrimSpace(string(content)))
+		}
+		return decoded, httpd.NewHttpError(httpResponse.StatusCode, cause)
+	}
+	return decoded, decodeErr
+}
+
+// Injects the status code, headers & cookies of the given HTTP response into the bound fields of the given response
+// struct, and returns a pointer to its "body" field (or nil if it has none).
+func injectResponseBindings(httpResponse *http.Response, bindings []httpd.Binding, structValue reflect.Value) (interface{}, error) {
+	var bodyTarget interface{}
+	for _, b := range bindings {
+		fieldValue := structValue.FieldByIndex(b.Field.Index)
+		switch b.Kind {
+		case httpd.BindingBody:
+			bodyTarget = fieldValue.Addr().Interface()
+		case httpd.BindingStatus:
+			if err := injectValues([]string{strconv.Itoa(httpResponse.StatusCode)}, fieldValue); err != nil {
+				return nil, errors.Wrapf(err, "failed injecting status into field '%s'", b.Field.Name)
+			}
+		case httpd.BindingHeader:
+			if values := httpResponse.Header[http.CanonicalHeaderKey(b.Name)]; len(values) > 0 {
+				if err := injectValues(values, fieldValue); err != nil {
+					return nil, errors.Wrapf(err, "failed injecting header '%s' into field '%s'", b.Name, b.Field.Name)
+				}
+			}
+		case httpd.BindingCookie:
+			for _, cookie := range httpResponse.Cookies() {
+				if cookie.Name != b.Name {
+					continue
+				} else if fieldValue.Type() == reflect.TypeOf(cookie) {
+					fieldValue.Set(reflect.ValueOf(cookie))
+				} else if fieldValue.Type() == reflect.TypeOf(*cookie) {
+					fieldValue.Set(reflect.ValueOf(*cookie))
+				} else if err := injectValues([]string{cookie.Value}, fieldValue); err != nil {
+					return nil, errors.Wrapf(err, "failed injecting cookie '%s' into field '%s'", b.Name, b.Field.Name)
+				}
+			}
+		}
+	}
+	return bodyTarget, nil
+}
+
+// Injects the given string values into the given scalar, time, pointer or slice value.
+func injectValues(values []string, targetValue reflect.Value) error {
+	switch targetValue.Kind() {
+	case reflect.Ptr:
+		ptrValue := reflect.New(targetValue.Type().Elem())
+		if err := injectValues(values, ptrValue.Elem()); err != nil {
+			return err
+		}
+		targetValue.Set(ptrValue)
+		return nil
+	case reflect.Slice:
+		sliceValue := reflect.MakeSlice(targetValue.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := injectValues([]string{v}, sliceValue.Index(i)); err != nil {
+				return err
+			}
+		}
+		targetValue.Set(sliceValue)
+		return nil
+	case reflect.String:
+		targetValue.SetString(values[0])
+		return nil
+	}
+
+	if targetValue.Type() == timeType {
+		t, err := http.ParseTime(values[0])
+		if err != nil {
+			return err
+		}
+		targetValue.Set(reflect.ValueOf(t))
+		return nil
+	}
+	_, err := fmt.Sscan(values[0], targetValue.Addr().Interface())
+	return err
+}
+
+// Formats the given field value as strings, as expected by the server's request decoder. Returns no values for nil
+// pointers & empty strings, which the server decodes as zero values anyway.
+func formatValues(value reflect.Value) []string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice:
+		values := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, formatValues(value.Index(i))...)
+		}
+		return values
+	case reflect.String:
+		if value.Len() == 0 {
+			return nil
+		}
+	}
+	return []string{fmt.Sprint(value.Interface())}
+}
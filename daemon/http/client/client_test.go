@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type user struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type updateUserRequest struct {
+	Org    string   `http:"path,org"`
+	ID     int      `http:"path,id"`
+	Fields []string `http:"query,fields"`
+	Limit  *int     `http:"query,limit"`
+	Token  string   `http:"header,x-token"`
+	Flag   bool     `http:"cookie,flag"`
+	User   *user    `http:"body"`
+}
+
+type updateUserResponse struct {
+	Org    string   `json:"org"`
+	ID     int      `json:"id"`
+	Fields []string `json:"fields"`
+	Limit  *int     `json:"limit"`
+	Token  string   `json:"token"`
+	Flag   bool     `json:"flag"`
+	User   *user    `json:"user"`
+}
+
+type createUserRequest struct {
+	User *user `http:"body"`
+}
+
+type createUserResponse struct {
+	Status   int     `http:"status"`
+	Location *string `http:"header,Location"`
+	Session  string  `http:"cookie,session"`
+	User     *user   `http:"body"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("UpdateUser", func(ctx context.Context, req *updateUserRequest) (*updateUserResponse, error) {
+		switch req.Token {
+		case "forbidden":
+			return nil, httpd.NewHttpError(http.StatusForbidden, errors.New("no access"))
+		case "empty":
+			return nil, nil
+		}
+		return &updateUserResponse{req.Org, req.ID, req.Fields, req.Limit, req.Token, req.Flag, req.User}, nil
+	})
+	ms.AddMethod("CreateUser", func(ctx context.Context, req *createUserRequest) (*createUserResponse, error) {
+		location := "/users/" + req.User.Name
+		return &createUserResponse{Status: http.StatusCreated, Location: &location, Session: "s1", User: req.User}, nil
+	})
+	routes := httpd.NewRoutes().
+		Method(http.MethodPut, "/orgs/{org}/users/{id}", "UpdateUser").
+		Method(http.MethodPost, "/users", "CreateUser")
+	router, err := httpd.NewRouter(ms, &httpd.Config{}, routes)
+	require.NoError(t, err)
+	return httptest.NewServer(router)
+}
+
+func TestCall(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	c := New(server.URL + "/")
+	limit := 5
+
+	t.Run("encodes_request", func(t *testing.T) {
+		response := &updateUserResponse{}
+		err := c.Call(context.Background(), http.MethodPut, "/orgs/{org}/users/{id}", &updateUserRequest{
+			Org:    "acme corp",
+			ID:     0,
+			Fields: []string{"name", "tags"},
+			Limit:  &limit,
+			Token:  "secret",
+			Flag:   true,
+			User:   &user{Name: "Jack", Tags: []string{"a"}},
+		}, response)
+		require.NoError(t, err)
+		require.Equal(t, &updateUserResponse{
+			Org:    "acme corp",
+			ID:     0,
+			Fields: []string{"name", "tags"},
+			Limit:  &limit,
+			Token:  "secret",
+			Flag:   true,
+			User:   &user{Name: "Jack", Tags: []string{"a"}},
+		}, response)
+	})
+	t.Run("error_status", func(t *testing.T) {
+		err := c.Call(context.Background(), http.MethodPut, "/orgs/{org}/users/{id}", updateUserRequest{Org: "a", Token: "forbidden"}, nil)
+		require.Error(t, err)
+		httpErr, ok := err.(httpd.ErrHttp)
+		require.True(t, ok)
+		require.Equal(t, http.StatusForbidden, httpErr.Code())
+	})
+	t.Run("response_bindings", func(t *testing.T) {
+		response := &createUserResponse{}
+		err := c.Call(context.Background(), http.MethodPost, "/users", &createUserRequest{User: &user{Name: "jack"}}, response)
+		require.NoError(t, err)
+		location := "/users/jack"
+		require.Equal(t, &createUserResponse{Status: http.StatusCreated, Location: &location, Session: "s1", User: &user{Name: "jack"}}, response)
+	})
+	t.Run("missing_path_parameter", func(t *testing.T) {
+		err := c.Call(context.Background(), http.MethodPut, "/orgs/{org}/users/{id}", updateUserRequest{}, nil)
+		require.EqualError(t, err, "empty value for path parameter 'org'")
+	})
+	t.Run("invalid_request", func(t *testing.T) {
+		err := c.Call(context.Background(), http.MethodGet, "/", struct{ P string }{}, nil)
+		require.EqualError(t, err, "invalid request type 'struct { P string }': missing 'http' tag for field 'P'")
+	})
+}
+
+func TestBind(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+	c := New(server.URL, WithHeader("x-token", "default"))
+
+	var updateUser func(ctx context.Context, req *updateUserRequest) (*updateUserResponse, error)
+	require.NoError(t, c.Bind(http.MethodPut, "/orgs/{org}/users/{id}", &updateUser))
+
+	t.Run("success", func(t *testing.T) {
+		response, err := updateUser(context.Background(), &updateUserRequest{Org: "acme", ID: 3})
+		require.NoError(t, err)
+		require.Equal(t, "acme", response.Org)
+		require.Equal(t, 3, response.ID)
+		require.Equal(t, "default", response.Token)
+	})
+	t.Run("empty_response", func(t *testing.T) {
+		response, err := updateUser(context.Background(), &updateUserRequest{Org: "acme", Token: "empty"})
+		require.NoError(t, err)
+		require.Nil(t, response)
+	})
+	t.Run("error_status", func(t *testing.T) {
+		response, err := updateUser(context.Background(), &updateUserRequest{Org: "acme", Token: "forbidden"})
+		require.Nil(t, response)
+		require.Equal(t, http.StatusForbidden, err.(httpd.ErrHttp).Code())
+	})
+	t.Run("wrong_signature", func(t *testing.T) {
+		var f func(req *updateUserRequest) error
+		require.EqualError(t, c.Bind(http.MethodGet, "/", &f), "wrong signature - must be func(context.Context, *RequestStruct) (*ResponseStruct, error), found: func(*client.updateUserRequest) error")
+		require.EqualError(t, c.Bind(http.MethodGet, "/", f), "expected pointer to function; received 'func(*client.updateUserRequest) error'")
+	})
+}
@@ -0,0 +1,350 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Decodes request bodies of a specific media type.
+type BodyDecoder interface {
+	// Decodes the given body into the value pointed to by v.
+	Decode(body io.Reader, v interface{}) error
+}
+
+// Encodes response bodies in a specific media type.
+type BodyEncoder interface {
+	// Encodes v into the given writer; output should be indented (if supported) when indent is true.
+	Encode(w io.Writer, v interface{}, indent bool) error
+}
+
+// Decodes & encodes bodies of a specific media type.
+type Codec interface {
+	BodyDecoder
+	BodyEncoder
+}
+
+type codecRegistry struct {
+	sync.RWMutex
+	decoders map[string]BodyDecoder
+	encoders map[string]BodyEncoder
+}
+
+var codecs = &codecRegistry{decoders: make(map[string]BodyDecoder), encoders: make(map[string]BodyEncoder)}
+
+func init() {
+	RegisterCodec("application/json", &jsonCodec{})
+	RegisterCodec("application/xml", &xmlCodec{})
+	RegisterCodec("text/xml", &xmlCodec{})
+	RegisterCodec("application/x-www-form-urlencoded", &formCodec{})
+}
+
+// Registers the given decoder for request bodies of the given media type, replacing any decoder previously registered
+// for it.
+func RegisterDecoder(mediaType string, decoder BodyDecoder) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.decoders[strings.ToLower(mediaType)] = decoder
+}
+
+// Registers the given encoder for responses of the given media type, replacing any encoder previously registered for it.
+func RegisterEncoder(mediaType string, encoder BodyEncoder) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.encoders[strings.ToLower(mediaType)] = encoder
+}
+
+// Registers the given codec for both request bodies & responses of the given media type.
+func RegisterCodec(mediaType string, codec Codec) {
+	RegisterDecoder(mediaType, codec)
+	RegisterEncoder(mediaType, codec)
+}
+
+// Returns the decoder registered for the given media type, or nil if none.
+func decoderFor(mediaType string) BodyDecoder {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.decoders[strings.ToLower(mediaType)]
+}
+
+// Returns the encoder registered for the given media type, or nil if none.
+func encoderFor(mediaType string) BodyEncoder {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.encoders[strings.ToLower(mediaType)]
+}
+
+// Returns the media types that responses can be encoded in, sorted, but with the default media type first.
+func encodableMediaTypes() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	mediaTypes := make([]string, 0, len(codecs.encoders))
+	for mediaType := range codecs.encoders {
+		if mediaType != DefaultMediaType {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	sort.Strings(mediaTypes)
+	if _, ok := codecs.encoders[DefaultMediaType]; ok {
+		mediaTypes = append([]string{DefaultMediaType}, mediaTypes...)
+	}
+	return mediaTypes
+}
+
+// Returns the decoder for bodies of the given "Content-Type" header value, along with its media type. Structured syntax
+// suffixes (eg. "application/merge-patch+json") fall back to the decoder of their base media type (eg.
+// "application/json"), and only UTF-8 (or ASCII) charsets are accepted. Errors are ErrHttp errors with HTTP 415.
+func resolveDecoder(contentType string) (BodyDecoder, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", NewHttpError(http.StatusUnsupportedMediaType, errors.Wrapf(err, "malformed content type '%s'", contentType))
+	}
+	if charset, ok := params["charset"]; ok {
+		switch strings.ToLower(charset) {
+		case "utf-8", "utf8", "us-ascii":
+		default:
+			return nil, "", NewHttpError(http.StatusUnsupportedMediaType, errors.Errorf("charset '%s' is not supported", charset))
+		}
+	}
+
+	decoder := decoderFor(mediaType)
+	if decoder == nil {
+		if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+			decoder = decoderFor("application/" + mediaType[i+1:])
+		}
+	}
+	if decoder == nil {
+		return nil, "", NewHttpError(http.StatusUnsupportedMediaType, errors.Errorf("'%s' is not supported", mediaType))
+	}
+	return decoder, mediaType, nil
+}
+
+// Middleware responding with HTTP 415 to requests with bodies no decoder is registered for.
+func allowDecodableContentTypes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 {
+			if _, _, err := resolveDecoder(r.Header.Get("Content-Type")); err != nil {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type jsonCodec struct{}
+
+func (c *jsonCodec) Decode(body io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func (c *jsonCodec) Encode(w io.Writer, v interface{}, indent bool) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if indent {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
+
+type xmlCodec struct{}
+
+func (c *xmlCodec) Decode(body io.Reader, v interface{}) error {
+	return xml.NewDecoder(body).Decode(v)
+}
+
+func (c *xmlCodec) Encode(w io.Writer, v interface{}, indent bool) error {
+	encoder := xml.NewEncoder(w)
+	if indent {
+		encoder.Indent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Decodes & encodes "application/x-www-form-urlencoded" bodies from & into structs (or string maps). Struct fields are
+// named by their "form" tag, falling back to their "json" tag & then to their name, and must be scalars, pointers to
+// scalars or slices of scalars.
+type formCodec struct{}
+
+func (c *formCodec) Decode(body io.Reader, v interface{}) error {
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	values, err := neturl.ParseQuery(string(content))
+	if err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return errors.Errorf("expected non-nil pointer; received '%T'", v)
+	}
+	target = target.Elem()
+	for target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+
+	switch target.Kind() {
+	case reflect.Map:
+		if target.Type().Key().Kind() != reflect.String {
+			return errors.Errorf("form values cannot be decoded into '%s'", target.Type())
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+		for name, vals := range values {
+			itemValue := reflect.New(target.Type().Elem()).Elem()
+			if err := injectFormValues(vals, itemValue); err != nil {
+				return errors.Wrapf(err, "failed decoding form value '%s'", name)
+			}
+			target.SetMapIndex(reflect.ValueOf(name).Convert(target.Type().Key()), itemValue)
+		}
+		return nil
+	case reflect.Struct:
+		for name, index := range formFields(target.Type()) {
+			if vals, ok := values[name]; ok {
+				if err := injectFormValues(vals, target.FieldByIndex(index)); err != nil {
+					return errors.Wrapf(err, "failed decoding form value '%s'", name)
+				}
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("form values cannot be decoded into '%s'", target.Type())
+	}
+}
+
+func (c *formCodec) Encode(w io.Writer, v interface{}, indent bool) error {
+	source := reflect.ValueOf(v)
+	for source.Kind() == reflect.Ptr || source.Kind() == reflect.Interface {
+		if source.IsNil() {
+			return nil
+		}
+		source = source.Elem()
+	}
+
+	values := make(neturl.Values)
+	switch source.Kind() {
+	case reflect.Map:
+		if source.Type().Key().Kind() != reflect.String {
+			return errors.Errorf("'%s' cannot be encoded as form values", source.Type())
+		}
+		for _, key := range source.MapKeys() {
+			vals, err := formatFormValues(source.MapIndex(key))
+			if err != nil {
+				return err
+			}
+			values[key.String()] = vals
+		}
+	case reflect.Struct:
+		for name, index := range formFields(source.Type()) {
+			vals, err := formatFormValues(source.FieldByIndex(index))
+			if err != nil {
+				return errors.Wrapf(err, "failed encoding field '%s'", name)
+			}
+			if len(vals) > 0 {
+				values[name] = vals
+			}
+		}
+	default:
+		return errors.Errorf("'%s' cannot be encoded as form values", source.Type())
+	}
+	_, err := io.WriteString(w, values.Encode())
+	return err
+}
+
+// Returns the indices of the exported fields of the given struct type, keyed by their form value names.
+func formFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("form"); ok {
+			name = strings.Split(tag, ",")[0]
+		} else if jsonName, _ := parseJSONTag(field); jsonName != "" {
+			name = jsonName
+		}
+		if name != "-" && name != "" {
+			fields[name] = field.Index
+		}
+	}
+	return fields
+}
+
+// Injects the given form values into the given scalar, pointer or slice value.
+func injectFormValues(values []string, targetValue reflect.Value) error {
+	switch targetValue.Kind() {
+	case reflect.Ptr:
+		ptrValue := reflect.New(targetValue.Type().Elem())
+		if err := injectFormValues(values, ptrValue.Elem()); err != nil {
+			return err
+		}
+		targetValue.Set(ptrValue)
+		return nil
+	case reflect.Slice:
+		sliceValue := reflect.MakeSlice(targetValue.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := injectFormValues([]string{v}, sliceValue.Index(i)); err != nil {
+				return err
+			}
+		}
+		targetValue.Set(sliceValue)
+		return nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.String:
+		if len(values) == 0 {
+			targetValue.Set(reflect.Zero(targetValue.Type()))
+			return nil
+		}
+		return injectScalarValue(values[0], targetValue)
+	default:
+		return errors.Errorf("form values cannot be decoded into '%s'", targetValue.Type())
+	}
+}
+
+// Formats the given scalar, pointer or slice value as form values; nil pointers have no values.
+func formatFormValues(value reflect.Value) ([]string, error) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+		return formatFormValues(value.Elem())
+	case reflect.Slice, reflect.Array:
+		values := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			itemValues, err := formatFormValues(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.String:
+		return []string{fmt.Sprint(value.Interface())}, nil
+	default:
+		return nil, errors.Errorf("'%s' cannot be encoded as form values", value.Type())
+	}
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type codecTestUser struct {
+	Name  string   `json:"name" xml:"name"`
+	Age   *int     `json:"age,omitempty" xml:"age,omitempty"`
+	Tags  []string `form:"tag" json:"tags" xml:"tag"`
+	Admin bool
+}
+
+type codecTestText struct{ Text string }
+
+func (t *codecTestText) String() string { return t.Text }
+
+type upperCaseCodec struct{}
+
+func (c *upperCaseCodec) Decode(body io.Reader, v interface{}) error {
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(v).Elem().SetString(strings.ToLower(string(content)))
+	return nil
+}
+
+func (c *upperCaseCodec) Encode(w io.Writer, v interface{}, indent bool) error {
+	_, err := io.WriteString(w, strings.ToUpper(fmt.Sprint(v)))
+	return err
+}
+
+func TestFormCodec(t *testing.T) {
+	age := 30
+	user := &codecTestUser{Name: "Jack Smith", Age: &age, Tags: []string{"a", "b"}, Admin: true}
+
+	buffer := new(bytes.Buffer)
+	require.NoError(t, (&formCodec{}).Encode(buffer, user, true))
+	require.Equal(t, "Admin=true&age=30&name=Jack+Smith&tag=a&tag=b", buffer.String())
+
+	decoded := &codecTestUser{}
+	require.NoError(t, (&formCodec{}).Decode(strings.NewReader(buffer.String()), decoded))
+	require.Equal(t, user, decoded)
+
+	values := map[string][]string{}
+	require.NoError(t, (&formCodec{}).Decode(strings.NewReader("a=1&a=2&b=3"), &values))
+	require.Equal(t, map[string][]string{"a": {"1", "2"}, "b": {"3"}}, values)
+
+	require.EqualError(t, (&formCodec{}).Decode(strings.NewReader("age=old"), &codecTestUser{}), "failed decoding form value 'age': strconv.ParseInt: parsing \"old\": invalid syntax")
+	require.EqualError(t, (&formCodec{}).Encode(buffer, 3, false), "'int' cannot be encoded as form values")
+}
+
+func TestCodecRegistry(t *testing.T) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("UpdateUser", func(ctx context.Context, req *struct {
+		User *codecTestUser `http:"body"`
+	}) (*codecTestUser, error) {
+		return req.User, nil
+	})
+	ms.AddMethod("Echo", func(ctx context.Context, req *struct {
+		Text string `http:"body"`
+	}) (*codecTestText, error) {
+		return &codecTestText{req.Text}, nil
+	})
+	RegisterCodec("text/x-upper", &upperCaseCodec{})
+	defer func() {
+		codecs.Lock()
+		defer codecs.Unlock()
+		delete(codecs.decoders, "text/x-upper")
+		delete(codecs.encoders, "text/x-upper")
+	}()
+
+	router, err := createRouter(ms, &Config{}, NewRoutes().Method(http.MethodPut, "/user", "UpdateUser").Method(http.MethodPost, "/echo", "Echo"))
+	require.NoError(t, err)
+	send := func(path, contentType, accept, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPut, url+path, strings.NewReader(body))
+		if path == "/echo" {
+			request.Method = http.MethodPost
+		}
+		request.Header.Set("content-type", contentType)
+		request.Header.Set("accept", accept)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("xml", func(t *testing.T) {
+		response := send("/user", "application/xml", "application/xml", `<codecTestUser><name>Jack</name><tag>a</tag></codecTestUser>`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "<codecTestUser>\n  <name>Jack</name>\n  <tag>a</tag>\n  <Admin>false</Admin>\n</codecTestUser>\n", response.Body.String())
+	})
+	t.Run("form", func(t *testing.T) {
+		response := send("/user", "application/x-www-form-urlencoded", "application/json", `name=Jack&tag=a&tag=b&Admin=true`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"name": "Jack", "tags": ["a", "b"], "Admin": true}`, response.Body.String())
+	})
+	t.Run("custom", func(t *testing.T) {
+		response := send("/echo", "text/x-upper", "text/x-upper", "Hello")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "HELLO", response.Body.String())
+	})
+	t.Run("charset_and_suffix", func(t *testing.T) {
+		response := send("/user", "application/merge-patch+json; charset=UTF-8", "application/json", `{"name": "Jack"}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"name": "Jack", "tags": null, "Admin": false}`, response.Body.String())
+	})
+	t.Run("unsupported_charset", func(t *testing.T) {
+		response := send("/user", "application/json; charset=iso-8859-1", "application/json", `{"name": "Jack"}`)
+		require.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+	})
+	t.Run("unsupported_content_type", func(t *testing.T) {
+		response := send("/user", "text/csv", "application/json", "name\nJack")
+		require.Equal(t, http.StatusUnsupportedMediaType, response.Code)
+	})
+}
+
+func TestResolveDecoder(t *testing.T) {
+	testCases := map[string]string{
+		"application/json":                           "application/json",
+		"Application/JSON; charset=utf-8":            "application/json",
+		`application/json; charset="UTF-8"`:          "application/json",
+		"application/vnd.myco.user+json":             "application/vnd.myco.user+json",
+		"application/merge-patch+json; charset=utf8": "application/merge-patch+json",
+		"application/atom+xml":                       "application/atom+xml",
+		"text/xml; charset=us-ascii":                 "text/xml",
+	}
+	for contentType, expected := range testCases {
+		decoder, mediaType, err := resolveDecoder(contentType)
+		require.NoError(t, err, contentType)
+		require.NotNil(t, decoder, contentType)
+		require.Equal(t, expected, mediaType, contentType)
+	}
+
+	_, _, err := resolveDecoder("application/json; charset=latin1")
+	require.EqualError(t, err, "415: charset 'latin1' is not supported")
+	_, _, err = resolveDecoder("application/vnd.myco+yaml")
+	require.EqualError(t, err, "415: 'application/vnd.myco+yaml' is not supported")
+	_, _, err = resolveDecoder("application/json;;")
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnsupportedMediaType, err.(ErrHttp).Code())
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Method metadata key overriding the HTTP method derived for a method by convention.
+	MetadataHTTPMethod = "http.method"
+
+	// Method metadata key overriding the path derived for a method by convention.
+	MetadataHTTPPath = "http.path"
+)
+
+// Maps method name prefixes to the HTTP methods they are routed to; the rest of the name is the resource name.
+var conventionalVerbs = []struct {
+	prefix     string
+	httpMethod string
+}{
+	{"Get", http.MethodGet},
+	{"List", http.MethodGet},
+	{"Create", http.MethodPost},
+	{"Update", http.MethodPut},
+	{"Replace", http.MethodPut},
+	{"Patch", http.MethodPatch},
+	{"Delete", http.MethodDelete},
+	{"Remove", http.MethodDelete},
+}
+
+// ConventionalRoutes can be added to a routes group (or placed in the routes map given to NewHTTPServer), and routes the
+// micro-service methods to HTTP methods & paths derived from their names, under the group's prefix (or the map key,
+// which may be empty). For example, "GetUser" is routed to "GET /users/{id}" and "CreateUser" to "POST /users". Path
+// parameters are appended to the path in the order of the "path" fields of the request struct. Methods not following a
+// known naming convention are routed to "POST" with their name in kebab-case (eg. "SendEmail" to "POST /send-email").
+// The derived HTTP method & path can be overridden by the "http.method" & "http.path" method metadata.
+type ConventionalRoutes struct {
+	ms          *msvc.MicroService
+	methodNames []string
+}
+
+// Creates conventional routes for the given methods, or for all methods registered when the HTTP server is created, if
+// no method names are given.
+func NewConventionalRoutes(ms *msvc.MicroService, methodNames ...string) *ConventionalRoutes {
+	return &ConventionalRoutes{ms: ms, methodNames: methodNames}
+}
+
+type conventionalRoute struct {
+	httpMethod string
+	path       string
+	descriptor *msvc.MethodDescriptor
+}
+
+func (c *ConventionalRoutes) routes() ([]conventionalRoute, error) {
+	descriptors := make([]*msvc.MethodDescriptor, 0)
+	if len(c.methodNames) == 0 {
+		descriptors = c.ms.Methods()
+	} else {
+		for _, name := range c.methodNames {
+			descriptor := c.ms.GetMethodDescriptor(name)
+			if descriptor == nil {
+				return nil, errors.Errorf("method '%s' not found", name)
+			}
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+
+	routes := make([]conventionalRoute, 0, len(descriptors))
+	routesByKey := make(map[string]string)
+	for _, descriptor := range descriptors {
+		httpMethod, path, err := deriveRoute(descriptor)
+		if err != nil {
+			return nil, err
+		}
+		key := httpMethod + " " + path
+		if other, ok := routesByKey[key]; ok {
+			return nil, errors.Errorf("methods '%s' and '%s' are both routed to '%s'", other, descriptor.Name, key)
+		}
+		routesByKey[key] = descriptor.Name
+		routes = append(routes, conventionalRoute{httpMethod, path, descriptor})
+	}
+	return routes, nil
+}
+
+// Derives the HTTP method & path for the given method, from its name & request struct, or from its metadata.
+func deriveRoute(descriptor *msvc.MethodDescriptor) (string, string, error) {
+	httpMethod, resource := http.MethodPost, descriptor.Name
+	plural := false
+	for _, verb := range conventionalVerbs {
+		// The verb must be a whole word, ie. followed by an upper-case letter (so "Getaway" is not "Get" + "away")
+		if strings.HasPrefix(descriptor.Name, verb.prefix) && len(descriptor.Name) > len(verb.prefix) &&
+			unicode.IsUpper([]rune(descriptor.Name[len(verb.prefix):])[0]) {
+			httpMethod, resource, plural = verb.httpMethod, descriptor.Name[len(verb.prefix):], true
+			break
+		}
+	}
+	if override, ok := descriptor.Metadata[MetadataHTTPMethod]; ok {
+		httpMethod = strings.ToUpper(override)
+	}
+
+	if path, ok := descriptor.Metadata[MetadataHTTPPath]; ok {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return httpMethod, path, nil
+	}
+
+	bindings, err := ParseBindings(descriptor.Adapter.RequestType())
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed deriving route for method '%s'", descriptor.Name)
+	}
+	resource = kebabCase(resource)
+	if plural {
+		resource = pluralize(resource)
+	}
+	path := "/" + resource
+	for _, b := range bindings {
+		if b.Kind == BindingPath {
+			path += "/{" + b.Name + "}"
+		}
+	}
+	return httpMethod, path, nil
+}
+
+// Converts a Go identifier (eg. "OrderItem" or "HTTPServer") to kebab-case (eg. "order-item" or "http-server").
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+			if startsWord {
+				sb.WriteRune('-')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// Pluralizes the last word of the given kebab-case resource name, unless it is plural already.
+func pluralize(resource string) string {
+	switch {
+	case strings.HasSuffix(resource, "s"):
+		return resource
+	case strings.HasSuffix(resource, "y") && len(resource) > 1 && !strings.ContainsRune("aeiou", rune(resource[len(resource)-2])):
+		return resource[:len(resource)-1] + "ies"
+	case strings.HasSuffix(resource, "x"), strings.HasSuffix(resource, "z"),
+		strings.HasSuffix(resource, "ch"), strings.HasSuffix(resource, "sh"):
+		return resource + "es"
+	default:
+		return resource + "s"
+	}
+}
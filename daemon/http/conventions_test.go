@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type conventionsUserReq struct {
+	ID string `http:"path,id"`
+}
+
+type conventionsUserRes struct {
+	Name string `json:"name"`
+}
+
+func conventionsUserMethod(name string) func(ctx context.Context, req *conventionsUserReq) (*conventionsUserRes, error) {
+	return func(ctx context.Context, req *conventionsUserReq) (*conventionsUserRes, error) {
+		return &conventionsUserRes{Name: name + ":" + req.ID}, nil
+	}
+}
+
+func TestDeriveRoute(t *testing.T) {
+	type NoParams struct{}
+	noParams := func(ctx context.Context, req *NoParams) (*conventionsUserRes, error) { return nil, nil }
+
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		method  interface{}
+		options []msvc.MethodOption
+		verb    string
+		path    string
+	}{
+		{"GetUser", conventionsUserMethod("get"), nil, http.MethodGet, "/users/{id}"},
+		{"ListUsers", noParams, nil, http.MethodGet, "/users"},
+		{"CreateUser", noParams, nil, http.MethodPost, "/users"},
+		{"UpdateUser", conventionsUserMethod("update"), nil, http.MethodPut, "/users/{id}"},
+		{"PatchUser", conventionsUserMethod("patch"), nil, http.MethodPatch, "/users/{id}"},
+		{"DeleteUser", conventionsUserMethod("delete"), nil, http.MethodDelete, "/users/{id}"},
+		{"RemoveOrderItem", noParams, nil, http.MethodDelete, "/order-items"},
+		{"ListCategory", noParams, nil, http.MethodGet, "/categories"},
+		{"GetBox", noParams, nil, http.MethodGet, "/boxes"},
+		{"GetHTTPServer", noParams, nil, http.MethodGet, "/http-servers"},
+		{"SendEmail", noParams, nil, http.MethodPost, "/send-email"},
+		{"Get", noParams, nil, http.MethodPost, "/get"},
+		{"Getaway", noParams, nil, http.MethodPost, "/getaway"},
+		{"Listen", noParams, nil, http.MethodPost, "/listen"},
+		{"Removal", noParams, nil, http.MethodPost, "/removal"},
+		{"Updated", noParams, nil, http.MethodPost, "/updated"},
+		{"Reindex", noParams, []msvc.MethodOption{msvc.WithMetadata(MetadataHTTPMethod, "put")}, http.MethodPut, "/reindex"},
+		{"Login", noParams, []msvc.MethodOption{msvc.WithMetadata(MetadataHTTPPath, "auth/login")}, http.MethodPost, "/auth/login"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms.AddMethod(tc.name, tc.method, tc.options...)
+			verb, path, err := deriveRoute(ms.GetMethodDescriptor(tc.name))
+			require.NoError(t, err)
+			require.Equal(t, tc.verb, verb)
+			require.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestConventionalRoutes(t *testing.T) {
+	newService := func(t *testing.T) *msvc.MicroService {
+		ms, err := msvc.New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("GetUser", conventionsUserMethod("get"))
+		ms.AddMethod("DeleteUser", conventionsUserMethod("delete"))
+		return ms
+	}
+	call := func(router http.Handler, method, path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, url+path, nil)
+		request.Header.Set("accept", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	t.Run("mounted_at_root", func(t *testing.T) {
+		ms := newService(t)
+		router, err := createRouter(ms, &Config{}, NewRoutes().Conventional(NewConventionalRoutes(ms)))
+		require.NoError(t, err)
+
+		response := call(router, http.MethodGet, "/users/1")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"name":"get:1"}`, response.Body.String())
+
+		response = call(router, http.MethodDelete, "/users/2")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"name":"delete:2"}`, response.Body.String())
+	})
+	t.Run("mixed_with_explicit_routes", func(t *testing.T) {
+		ms := newService(t)
+		router, err := createRouter(ms, &Config{}, RoutesFromMap(map[string]interface{}{
+			"api": NewConventionalRoutes(ms, "GetUser"),
+			"admin/{id}": map[string]interface{}{
+				"DELETE": NewHandler(ms.GetMethodAdapter("DeleteUser")),
+			},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, call(router, http.MethodGet, "/api/users/1").Code)
+		require.Equal(t, http.StatusMethodNotAllowed, call(router, http.MethodDelete, "/api/users/1").Code)
+		require.Equal(t, http.StatusOK, call(router, http.MethodDelete, "/admin/1").Code)
+	})
+	t.Run("middleware", func(t *testing.T) {
+		ms := newService(t)
+		router, err := createRouter(ms, &Config{}, NewRoutes().Conventional(NewConventionalRoutes(ms)))
+		require.NoError(t, err)
+		ms.AddMiddleware(func(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				response, err := method(ctx, request)
+				if res, ok := response.(*conventionsUserRes); ok {
+					res.Name += "!"
+				}
+				return response, err
+			}
+		}, msvc.ForMethods("GetUser"))
+
+		response := call(router, http.MethodGet, "/users/1")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"name":"get:1!"}`, response.Body.String())
+		require.JSONEq(t, `{"name":"delete:2"}`, call(router, http.MethodDelete, "/users/2").Body.String())
+	})
+	t.Run("unknown_method", func(t *testing.T) {
+		ms := newService(t)
+		_, err := NewConventionalRoutes(ms, "Missing").routes()
+		require.EqualError(t, err, "method 'Missing' not found")
+		routes := NewRoutes().AllowUnboundMethods()
+		routes.Group("v1").Conventional(NewConventionalRoutes(ms, "Missing"))
+		_, err = createRouter(ms, &Config{}, routes)
+		require.EqualError(t, err, "invalid HTTP routes:\nbad conventional routes under '/v1': method 'Missing' not found")
+	})
+	t.Run("conflict", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMethod("FetchUser", conventionsUserMethod("fetch"),
+			msvc.WithMetadata(MetadataHTTPMethod, "GET"), msvc.WithMetadata(MetadataHTTPPath, "/users/{id}"))
+		_, err := NewConventionalRoutes(ms).routes()
+		require.EqualError(t, err, "methods 'FetchUser' and 'GetUser' are both routed to 'GET /users/{id}'")
+	})
+}
@@ -14,18 +14,41 @@ type handler struct {
 	requestDecoder  RequestDecoder
 	responseEncoder ResponseEncoder
 	methodAdapter   msvc.MethodAdapter
+
+	// Micro-service & name of the method, for handlers of registered methods; calls are then dispatched through the
+	// method chain (so all middleware applies), rather than directly to the adapter
+	ms         *msvc.MicroService
+	methodName string
 }
 
 func NewHandler(methodAdapter msvc.MethodAdapter) *handler {
+	h, err := newHandler(methodAdapter)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func newHandler(methodAdapter msvc.MethodAdapter) (*handler, error) {
 	requestDecoder, err := newRequestDecoder(methodAdapter.RequestType())
 	if err != nil {
-		panic(errors.Wrapf(err, "failed creating request decoder for '%s'", methodAdapter.RequestType()))
+		return nil, errors.Wrapf(err, "failed creating request decoder for '%s'", methodAdapter.RequestType())
 	}
 	responseEncoder, err := newResponseEncoder(methodAdapter.ResponseType())
 	if err != nil {
-		panic(errors.Wrapf(err, "failed creating response encoder for '%s'", methodAdapter.ResponseType()))
+		return nil, errors.Wrapf(err, "failed creating response encoder for '%s'", methodAdapter.ResponseType())
 	}
-	return &handler{requestDecoder, responseEncoder, methodAdapter}
+	return &handler{requestDecoder: requestDecoder, responseEncoder: responseEncoder, methodAdapter: methodAdapter}, nil
+}
+
+// Creates a handler of the given registered method, dispatching calls through its method chain.
+func newMethodHandler(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor) (*handler, error) {
+	h, err := newHandler(descriptor.Adapter)
+	if err != nil {
+		return nil, err
+	}
+	h.ms, h.methodName = ms, descriptor.Name
+	return h, nil
 }
 
 func (h *handler) Handle(w http.ResponseWriter, r *http.Request) {
@@ -38,17 +61,32 @@ func (h *handler) Handle(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		r = r.WithContext(withLastEventID(r.Context(), lastEventID))
+	}
+
 	serviceRequest, err := h.requestDecoder.Decode(r)
 	if err != nil {
 		h.responseEncoder.MarshallServiceResponseAndError(nil, err, r, w)
 		return
 	}
 
-	serviceResponse, err := h.methodAdapter.Call(r.Context(), serviceRequest)
+	// Method chains are looked up on each call, since middleware may be added after the handler is created
+	call := msvc.Method(h.methodAdapter.Call)
+	if h.ms != nil {
+		if method := h.ms.GetMethod(h.methodName); method != nil {
+			call = method
+		}
+	}
+	serviceResponse, err := call(r.Context(), serviceRequest)
 	if err != nil {
 		h.responseEncoder.MarshallServiceResponseAndError(serviceResponse, err, r, w)
 		return
 	}
 
+	if stream, ok := serviceResponse.(msvc.Stream); ok {
+		serveStream(stream, r, w)
+		return
+	}
 	h.responseEncoder.MarshallServiceResponse(serviceResponse, r, w)
 }
@@ -57,7 +57,7 @@ func TestHandlerHandle(t *testing.T) {
 		response := httptest.NewRecorder()
 		handler.Handle(response, request)
 		require.Equal(t, http.StatusInternalServerError, response.Code)
-		require.Equal(t, "application/json", response.Header().Get("content-type"))
+		require.Equal(t, "application/json; charset=utf-8", response.Header().Get("content-type"))
 		require.Equal(t, "{\n  \"P\": \"v\"\n}\n", response.Body.String())
 	})
 	t.Run("method_panic", func(t *testing.T) {
@@ -87,7 +87,7 @@ func TestHandlerHandle(t *testing.T) {
 		response := httptest.NewRecorder()
 		handler.Handle(response, request)
 		require.Equal(t, http.StatusOK, response.Code)
-		require.Equal(t, "application/json", response.Header().Get("content-type"))
+		require.Equal(t, "application/json; charset=utf-8", response.Header().Get("content-type"))
 		require.Equal(t, "{\n  \"P\": \"v\"\n}\n", response.Body.String())
 	})
 }
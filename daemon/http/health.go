@@ -0,0 +1,48 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/go-chi/chi"
+	"net/http"
+)
+
+// Registers the micro-service liveness ("/livez"), readiness ("/readyz") and detailed health ("/healthz", also
+// available as "/health") endpoints.
+func mountHealthEndpoints(router chi.Router, ms *msvc.MicroService) {
+	router.Get("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthStatus(w, ms.Live())
+	})
+	router.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthStatus(w, ms.CheckHealth(r.Context()).Ready)
+	})
+	healthz := func(w http.ResponseWriter, r *http.Request) {
+		report := ms.CheckHealth(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status == msvc.HealthFail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		encoder := json.NewEncoder(w)
+		if ms.Environment() != msvc.EnvProduction {
+			encoder.SetIndent("", "  ")
+		}
+		if err := encoder.Encode(report); err != nil {
+			ms.Log("err", err, "msg", "failed encoding health report")
+		}
+	}
+	router.Get("/healthz", healthz)
+	router.Get("/health", healthz)
+}
+
+func writeHealthStatus(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "text/plain")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(msvc.HealthPass))
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(msvc.HealthFail))
+	}
+}
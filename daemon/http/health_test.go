@@ -0,0 +1,47 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddHealthCheck("db", func(ctx context.Context) error { return errors.New("down") })
+	router, err := createRouter(ms, &Config{}, NewRoutes())
+	require.NoError(t, err)
+
+	t.Run("livez", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+"/livez", nil))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "pass", response.Body.String())
+	})
+	t.Run("readyz", func(t *testing.T) {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+"/readyz", nil))
+		require.Equal(t, http.StatusServiceUnavailable, response.Code)
+		require.Equal(t, "fail", response.Body.String())
+	})
+	t.Run("healthz", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/health"} {
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+path, nil))
+			require.Equal(t, http.StatusServiceUnavailable, response.Code)
+			require.Equal(t, "application/json", response.Header().Get("content-type"))
+
+			report := msvc.HealthReport{}
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &report))
+			require.Equal(t, msvc.HealthFail, report.Status)
+			require.Len(t, report.Checks, 1)
+			require.Equal(t, "down", report.Checks[0].Error)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/go-chi/chi"
+	"net/http"
+	"reflect"
+)
+
+const (
+	// Path under which the HTTP server serves the JSON schemas of methods, unless configured otherwise.
+	DefaultSchemasPath = "/schemas"
+
+	// The JSON Schema dialect of generated schemas.
+	JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// Returns the JSON schema of the given method's request body, or nil if its request struct has no "body" field. Since
+// missing fields are accepted when decoding request bodies, only fields validated as required (via a "validate" tag)
+// are required by the schema.
+func RequestBodySchema(adapter msvc.MethodAdapter) (*Schema, error) {
+	bindings, err := ParseBindings(adapter.RequestType())
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bindings {
+		if b.Kind == BindingBody {
+			generator := newSchemaGenerator("#/$defs/")
+			generator.requests = true
+			return standaloneSchema(generator, b.Field.Type), nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns the JSON schema of the given method's response body, or nil if its response struct binds fields to other
+// parts of the HTTP response (via "http" tags) but none to the body.
+func ResponseSchema(adapter msvc.MethodAdapter) *Schema {
+	bodyType := responseBodyType(adapter.ResponseType())
+	if bodyType == nil {
+		return nil
+	}
+	return TypeSchema(bodyType)
+}
+
+// Returns a standalone JSON schema of the given type, as encoded by "encoding/json", with all referenced struct types
+// defined under "$defs".
+func TypeSchema(t reflect.Type) *Schema {
+	return standaloneSchema(newSchemaGenerator("#/$defs/"), t)
+}
+
+// Returns a standalone JSON schema of the given type, generated by the given generator, with all referenced struct types
+// defined under "$defs".
+func standaloneSchema(generator *schemaGenerator, t reflect.Type) *Schema {
+	schema := generator.schemaOf(t)
+	if schema == nil {
+		schema = &Schema{}
+	}
+	document := *schema
+	document.Schema = JSONSchemaDialect
+	if len(generator.definitions) > 0 {
+		document.Defs = generator.definitions
+	}
+	return &document
+}
+
+// Returns the JSON schemas of the given types, as encoded by "encoding/json", along with the definitions of all struct
+// types they refer to using the given reference prefix (eg. "#/components/schemas/").
+func TypeSchemas(refPrefix string, types ...reflect.Type) ([]*Schema, map[string]*Schema) {
+	generator := newSchemaGenerator(refPrefix)
+	schemas := make([]*Schema, len(types))
+	for i, t := range types {
+		if schemas[i] = generator.schemaOf(t); schemas[i] == nil {
+			schemas[i] = &Schema{}
+		}
+	}
+	return schemas, generator.definitions
+}
+
+// Registers endpoints serving the request body & response JSON schemas of each method, at "<path>/<method>/request" and
+// "<path>/<method>/response", respectively.
+func mountSchemaEndpoints(router chi.Router, ms *msvc.MicroService, path string) {
+	writeSchema := func(w http.ResponseWriter, schema *Schema) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		if ms.Environment() != msvc.EnvProduction {
+			encoder.SetIndent("", "  ")
+		}
+		if err := encoder.Encode(schema); err != nil {
+			ms.Log("err", err, "msg", "failed encoding JSON schema")
+		}
+	}
+	router.Get(joinPattern(path, "/{method}/request"), func(w http.ResponseWriter, r *http.Request) {
+		descriptor := ms.GetMethodDescriptor(chi.URLParam(r, "method"))
+		if descriptor == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		schema, err := RequestBodySchema(descriptor.Adapter)
+		if err != nil {
+			ms.Log("err", err, "msg", "failed generating JSON schema")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if schema == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeSchema(w, schema)
+	})
+	router.Get(joinPattern(path, "/{method}/response"), func(w http.ResponseWriter, r *http.Request) {
+		descriptor := ms.GetMethodDescriptor(chi.URLParam(r, "method"))
+		if descriptor == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		schema := ResponseSchema(descriptor.Adapter)
+		if schema == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeSchema(w, schema)
+	})
+}
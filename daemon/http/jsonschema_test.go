@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type jsonSchemaTestItem struct {
+	Name string `json:"name" validate:"required"`
+	Note string `json:"note"`
+}
+
+type jsonSchemaTestReq struct {
+	ID   string               `http:"path,id"`
+	Body []jsonSchemaTestItem `http:"body"`
+}
+
+type jsonSchemaTestRes struct {
+	Items []jsonSchemaTestItem `json:"items"`
+}
+
+func TestMethodSchemas(t *testing.T) {
+	withBody := msvc.NewAdapter(func(ctx context.Context, req *jsonSchemaTestReq) (*jsonSchemaTestRes, error) {
+		return nil, nil
+	})
+	withoutBody := msvc.NewAdapter(func(ctx context.Context, req *struct{}) (*jsonSchemaTestRes, error) {
+		return nil, nil
+	})
+
+	t.Run("request_body", func(t *testing.T) {
+		schema, err := RequestBodySchema(withBody)
+		require.NoError(t, err)
+		actual, err := json.Marshal(schema)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"type": "array",
+			"items": {"$ref": "#/$defs/jsonSchemaTestItem"},
+			"$defs": {
+				"jsonSchemaTestItem": {
+					"type": "object",
+					"properties": {"name": {"type": "string"}, "note": {"type": "string"}},
+					"required": ["name"]
+				}
+			}
+		}`, string(actual))
+
+		schema, err = RequestBodySchema(withoutBody)
+		require.NoError(t, err)
+		require.Nil(t, schema)
+	})
+	t.Run("response", func(t *testing.T) {
+		actual, err := json.Marshal(ResponseSchema(withBody))
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"$ref": "#/$defs/jsonSchemaTestRes",
+			"$defs": {
+				"jsonSchemaTestItem": {
+					"type": "object",
+					"properties": {"name": {"type": "string"}, "note": {"type": "string"}},
+					"required": ["name", "note"]
+				},
+				"jsonSchemaTestRes": {
+					"type": "object",
+					"properties": {"items": {"type": "array", "items": {"$ref": "#/$defs/jsonSchemaTestItem"}}},
+					"required": ["items"]
+				}
+			}
+		}`, string(actual))
+	})
+	t.Run("type_schemas", func(t *testing.T) {
+		schemas, definitions := TypeSchemas("#/components/schemas/", reflect.TypeOf(jsonSchemaTestRes{}), reflect.TypeOf(""))
+		actual, err := json.Marshal(map[string]interface{}{"schemas": schemas, "definitions": definitions})
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"schemas": [{"$ref": "#/components/schemas/jsonSchemaTestRes"}, {"type": "string"}],
+			"definitions": {
+				"jsonSchemaTestItem": {
+					"type": "object",
+					"properties": {"name": {"type": "string"}, "note": {"type": "string"}},
+					"required": ["name", "note"]
+				},
+				"jsonSchemaTestRes": {
+					"type": "object",
+					"properties": {"items": {"type": "array", "items": {"$ref": "#/components/schemas/jsonSchemaTestItem"}}},
+					"required": ["items"]
+				}
+			}
+		}`, string(actual))
+	})
+}
+
+func TestSchemaEndpoints(t *testing.T) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("PutItems", func(ctx context.Context, req *jsonSchemaTestReq) (*jsonSchemaTestRes, error) {
+		return nil, nil
+	})
+	ms.AddMethod("GetItems", func(ctx context.Context, req *struct{}) (*jsonSchemaTestRes, error) {
+		return nil, nil
+	})
+	router, err := createRouter(ms, &Config{}, NewRoutes().AllowUnboundMethods())
+	require.NoError(t, err)
+	get := func(path string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+path, nil))
+		return response
+	}
+
+	response := get("/schemas/PutItems/request")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "application/schema+json", response.Header().Get("content-type"))
+	schema := Schema{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &schema))
+	require.Equal(t, "array", schema.Type)
+
+	response = get("/schemas/GetItems/response")
+	require.Equal(t, http.StatusOK, response.Code)
+	schema = Schema{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &schema))
+	require.Equal(t, "#/$defs/jsonSchemaTestRes", schema.Ref)
+
+	require.Equal(t, http.StatusNotFound, get("/schemas/GetItems/request").Code)
+	require.Equal(t, http.StatusNotFound, get("/schemas/Missing/response").Code)
+}
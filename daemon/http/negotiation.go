@@ -0,0 +1,127 @@
+package http
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media type of responses to requests that accept any media type.
+const DefaultMediaType = "application/json"
+
+// A media range of an "Accept" header, with its quality value.
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Returns the specificity of the media range: 2 for exact media types, 1 for "type/*" and 0 for "*/*".
+func (a acceptRange) specificity() int {
+	if a.mediaType == "*/*" {
+		return 0
+	} else if strings.HasSuffix(a.mediaType, "/*") {
+		return 1
+	}
+	return 2
+}
+
+func (a acceptRange) matches(mediaType string) bool {
+	switch a.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(a.mediaType, "*"))
+	default:
+		return a.mediaType == mediaType
+	}
+}
+
+// Parses the given "Accept" header values into media ranges, in order of appearance. Malformed media ranges are ignored.
+func parseAccept(values []string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || !strings.Contains(mediaType, "/") {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+					continue
+				}
+			}
+			ranges = append(ranges, acceptRange{mediaType, quality})
+		}
+	}
+	return ranges
+}
+
+// Returns the best media type to encode the response of the given request in, among the media types encoders are
+// registered for, or false if the request accepts none of them. Requests without an "Accept" header (or with an empty
+// or malformed one) get the default media type (JSON). Between equally acceptable media types, the one matched more specifically (or earlier) by the
+// "Accept" header is chosen, and JSON is preferred for wildcards.
+func negotiateMediaType(r *http.Request) (string, bool) {
+	return negotiateMediaTypeAmong(r, encodableMediaTypes())
+}
+
+// Returns the best media type to encode the response of the given request in, among the given candidates (ordered by
+// preference, for requests without a valid "Accept" header & for wildcards), or false if the request accepts none of
+// them.
+func negotiateMediaTypeAmong(r *http.Request, candidates []string) (string, bool) {
+	ranges := parseAccept(r.Header[http.CanonicalHeaderKey("accept")])
+	if len(ranges) == 0 {
+		if len(candidates) == 0 {
+			return "", false
+		}
+		return candidates[0], true
+	}
+
+	best, bestQuality, bestSpecificity, bestIndex := "", 0.0, -1, len(ranges)
+	for _, mediaType := range candidates {
+		// Find the most specific media range matching this media type; ties are broken by order of appearance
+		matchIndex := -1
+		for i, a := range ranges {
+			if a.matches(mediaType) && (matchIndex < 0 || a.specificity() > ranges[matchIndex].specificity()) {
+				matchIndex = i
+			}
+		}
+		if matchIndex < 0 || ranges[matchIndex].quality == 0 {
+			continue
+		}
+
+		match := ranges[matchIndex]
+		if match.quality > bestQuality ||
+			match.quality == bestQuality && match.specificity() > bestSpecificity ||
+			match.quality == bestQuality && match.specificity() == bestSpecificity && matchIndex < bestIndex {
+			best, bestQuality, bestSpecificity, bestIndex = mediaType, match.quality, match.specificity(), matchIndex
+		}
+	}
+	return best, best != ""
+}
+
+// Returns the "Content-Type" header value for the given media type, adding a UTF-8 charset to textual media types.
+func contentTypeOf(mediaType string) string {
+	if isTextual(mediaType) {
+		return mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"})
+	}
+	return mediaType
+}
+
+// Returns whether the given media type is a textual one, which is encoded with a charset.
+func isTextual(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json" || mediaType == "application/xml":
+		return true
+	case strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml"):
+		return true
+	default:
+		return false
+	}
+}
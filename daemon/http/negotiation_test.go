@@ -0,0 +1,52 @@
+package http
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	testCases := map[string]string{
+		"":                                   "application/json",
+		"*/*":                                "application/json",
+		"application/json, text/plain;q=0.9": "application/json",
+		"text/html, application/xml;q=0.9, */*;q=0.8": "application/xml",
+		"application/*":                         "application/json",
+		"application/*;q=0.5, text/xml":         "text/xml",
+		"text/*, application/json;q=0.1":        "text/xml",
+		"*/*;q=0.1, application/json;q=0":       "application/x-www-form-urlencoded",
+		"APPLICATION/XML":                       "application/xml",
+		"application/xml;q=0.5, text/xml;q=0.5": "application/xml",
+		"text/xml;q=0.5, application/xml;q=0.5": "text/xml",
+		"application/json;q=bad, text/xml":      "text/xml",
+		"text/plain":                            "",
+		"application/json;q=0":                  "",
+	}
+	for accept, expected := range testCases {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			request.Header.Set("accept", accept)
+		}
+		mediaType, ok := negotiateMediaType(request)
+		require.Equal(t, expected, mediaType, "Accept: %s", accept)
+		require.Equal(t, expected != "", ok, "Accept: %s", accept)
+	}
+
+	// Present but empty or malformed headers are treated as missing
+	for _, accept := range []string{"", " ", "garbage, ;;", "application/json;q=bad"} {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		request.Header.Set("accept", accept)
+		mediaType, ok := negotiateMediaType(request)
+		require.True(t, ok, "Accept: %q", accept)
+		require.Equal(t, "application/json", mediaType, "Accept: %q", accept)
+	}
+}
+
+func TestContentTypeOf(t *testing.T) {
+	require.Equal(t, "application/json; charset=utf-8", contentTypeOf("application/json"))
+	require.Equal(t, "application/vnd.api+json; charset=utf-8", contentTypeOf("application/vnd.api+json"))
+	require.Equal(t, "text/xml; charset=utf-8", contentTypeOf("text/xml"))
+	require.Equal(t, "application/x-www-form-urlencoded", contentTypeOf("application/x-www-form-urlencoded"))
+}
@@ -0,0 +1,251 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/go-chi/chi"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Path at which the HTTP server serves its OpenAPI document, unless configured otherwise.
+	DefaultOpenAPIPath = "/openapi.json"
+
+	// Version of the OpenAPI specification generated documents conform to.
+	OpenAPIVersion = "3.1.0"
+)
+
+// OpenAPI is an OpenAPI 3.1 document, describing the HTTP routes of a micro-service.
+type OpenAPI struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents          `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// Maps lower-case HTTP methods (eg. "get") to the operations serving them.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Headers     map[string]*OpenAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIHeader struct {
+	Schema *Schema `json:"schema"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOption func(*OpenAPI)
+
+// Sets the version of the API described by the document; defaults to "0.0.0".
+func WithOpenAPIVersion(version string) OpenAPIOption {
+	return func(document *OpenAPI) {
+		document.Info.Version = version
+	}
+}
+
+func WithOpenAPIDescription(description string) OpenAPIOption {
+	return func(document *OpenAPI) {
+		document.Info.Description = description
+	}
+}
+
+// Lists the base URLs the API is served from.
+func WithOpenAPIServers(urls ...string) OpenAPIOption {
+	return func(document *OpenAPI) {
+		for _, url := range urls {
+			document.Servers = append(document.Servers, OpenAPIServer{URL: url})
+		}
+	}
+}
+
+// Generates the OpenAPI document describing the given routes. Only routes bound to micro-service methods, and to
+// specific HTTP methods, are described.
+func GenerateOpenAPI(ms *msvc.MicroService, routes *Routes, options ...OpenAPIOption) (*OpenAPI, error) {
+	resolvedRoutes, err := routes.resolve(ms)
+	if err != nil {
+		return nil, err
+	}
+	return generateOpenAPI(ms, resolvedRoutes, options...), nil
+}
+
+func generateOpenAPI(ms *msvc.MicroService, routes []*resolvedRoute, options ...OpenAPIOption) *OpenAPI {
+	document := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info:    OpenAPIInfo{Title: ms.Name(), Version: "0.0.0"},
+		Paths:   make(map[string]OpenAPIPathItem),
+	}
+	for _, option := range options {
+		option(document)
+	}
+
+	generator := newSchemaGenerator("#/components/schemas/")
+	for _, route := range routes {
+		if route.descriptor == nil || route.httpMethod == "" {
+			continue
+		}
+		path := openAPIPath(route.pattern)
+		if document.Paths[path] == nil {
+			document.Paths[path] = make(OpenAPIPathItem)
+		}
+		document.Paths[path][strings.ToLower(route.httpMethod)] = newOpenAPIOperation(generator, route.descriptor)
+	}
+	if len(generator.definitions) > 0 {
+		document.Components.Schemas = generator.definitions
+	}
+	return document
+}
+
+func newOpenAPIOperation(generator *schemaGenerator, descriptor *msvc.MethodDescriptor) *OpenAPIOperation {
+	operation := &OpenAPIOperation{
+		OperationID: descriptor.Name,
+		Description: descriptor.Description,
+		Tags:        descriptor.Tags,
+		Deprecated:  descriptor.Deprecated,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	// Describe parameters & request body from the request struct bindings
+	bindings, _ := ParseBindings(descriptor.Adapter.RequestType())
+	for _, b := range bindings {
+		if b.Kind == BindingBody {
+			operation.RequestBody = &OpenAPIRequestBody{
+				Required: b.Field.Type.Kind() != reflect.Ptr,
+				Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: generator.schemaOf(b.Field.Type)}},
+			}
+			operation.Responses[strconv.Itoa(http.StatusUnsupportedMediaType)] = &OpenAPIResponse{Description: "Unsupported request content type"}
+			continue
+		}
+		parameterType := b.Field.Type
+		if parameterType.Kind() == reflect.Ptr {
+			parameterType = parameterType.Elem()
+		}
+		operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+			Name:     b.Name,
+			In:       b.Kind,
+			Required: b.Kind == BindingPath,
+			Schema:   generator.schemaOf(parameterType),
+		})
+	}
+	if len(bindings) > 0 {
+		// Parameters & bodies that fail to decode are rejected
+		operation.Responses[strconv.Itoa(http.StatusBadRequest)] = &OpenAPIResponse{Description: "Invalid request"}
+	}
+
+	// Describe the response (its status code is any 2xx if bound to a response struct field) & error responses
+	response := &OpenAPIResponse{Description: "Successful response"}
+	status := strconv.Itoa(http.StatusOK)
+	responseBindings, _ := ParseResponseBindings(descriptor.Adapter.ResponseType())
+	for _, b := range responseBindings {
+		switch b.Kind {
+		case BindingStatus:
+			status = "2XX"
+		case BindingHeader:
+			headerType := b.Field.Type
+			if headerType.Kind() == reflect.Ptr {
+				headerType = headerType.Elem()
+			}
+			if response.Headers == nil {
+				response.Headers = make(map[string]*OpenAPIHeader)
+			}
+			schema := &Schema{Type: "string"} // times are formatted as HTTP dates, not RFC 3339 date-times
+			if headerType != timeType {
+				schema = generator.schemaOf(headerType)
+			}
+			response.Headers[http.CanonicalHeaderKey(b.Name)] = &OpenAPIHeader{Schema: schema}
+		}
+	}
+	if msvc.IsStreaming(descriptor.Adapter) {
+		// Streaming methods send their responses as server-sent events, a JSON array or newline-delimited JSON
+		itemSchema := generator.schemaOf(descriptor.Adapter.ResponseType())
+		response.Content = map[string]*OpenAPIMediaType{
+			EventStreamMediaType: {Schema: itemSchema},
+			"application/json":   {Schema: &Schema{Type: "array", Items: itemSchema}},
+			NDJSONMediaType:      {Schema: itemSchema},
+		}
+	} else if bodyType := responseBodyType(descriptor.Adapter.ResponseType()); bodyType != nil {
+		response.Content = map[string]*OpenAPIMediaType{"application/json": {Schema: generator.schemaOf(bodyType)}}
+	}
+	operation.Responses[status] = response
+	operation.Responses[strconv.Itoa(http.StatusNotAcceptable)] = &OpenAPIResponse{Description: "Unsupported response content type"}
+	operation.Responses[strconv.Itoa(http.StatusInternalServerError)] = &OpenAPIResponse{Description: "Internal error"}
+	return operation
+}
+
+// Converts a route pattern to an OpenAPI path, by removing regular expressions from path parameters (eg.
+// "/users/{id:[0-9]+}" becomes "/users/{id}").
+func openAPIPath(pattern string) string {
+	var sb strings.Builder
+	last := 0
+	for _, parameter := range parsePatternParameters(pattern) {
+		if parameter.name == "*" {
+			continue
+		}
+		sb.WriteString(pattern[last:parameter.start])
+		sb.WriteString("{" + parameter.name + "}")
+		last = parameter.end
+	}
+	sb.WriteString(pattern[last:])
+	return sb.String()
+}
+
+// Registers an endpoint serving the given OpenAPI document as JSON.
+func mountOpenAPIEndpoint(router chi.Router, ms *msvc.MicroService, path string, document *OpenAPI) {
+	router.Get(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		if ms.Environment() != msvc.EnvProduction {
+			encoder.SetIndent("", "  ")
+		}
+		if err := encoder.Encode(document); err != nil {
+			ms.Log("err", err, "msg", "failed encoding OpenAPI document")
+		}
+	})
+}
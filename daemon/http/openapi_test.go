@@ -0,0 +1,164 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type openAPITestUser struct {
+	Name string `json:"name"`
+}
+
+type openAPITestReq struct {
+	ID     int              `http:"path,id"`
+	Fields []string         `http:"query,fields"`
+	Token  *string          `http:"header,x-token"`
+	User   *openAPITestUser `http:"body"`
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("UpdateUser", func(ctx context.Context, req *openAPITestReq) (*openAPITestUser, error) {
+		return req.User, nil
+	}, msvc.WithDescription("Updates a user"), msvc.WithTags("users"), msvc.Deprecated())
+
+	routes := NewRoutes()
+	routes.Group("/v1").Method(http.MethodPut, "/users/{id:[0-9]+}", "UpdateUser")
+	document, err := GenerateOpenAPI(ms, routes, WithOpenAPIVersion("1.2.3"), WithOpenAPIServers("https://api.example.com"))
+	require.NoError(t, err)
+
+	actual, err := json.Marshal(document)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"openapi": "3.1.0",
+		"info": {"title": "test", "version": "1.2.3"},
+		"servers": [{"url": "https://api.example.com"}],
+		"paths": {
+			"/v1/users/{id}": {
+				"put": {
+					"operationId": "UpdateUser",
+					"description": "Updates a user",
+					"tags": ["users"],
+					"deprecated": true,
+					"parameters": [
+						{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "format": "int64"}},
+						{"name": "fields", "in": "query", "schema": {"type": "array", "items": {"type": "string"}}},
+						{"name": "x-token", "in": "header", "schema": {"type": "string"}}
+					],
+					"requestBody": {
+						"content": {
+							"application/json": {
+								"schema": {"anyOf": [{"$ref": "#/components/schemas/openAPITestUser"}, {"type": "null"}]}
+							}
+						}
+					},
+					"responses": {
+						"200": {
+							"description": "Successful response",
+							"content": {"application/json": {"schema": {"$ref": "#/components/schemas/openAPITestUser"}}}
+						},
+						"400": {"description": "Invalid request"},
+						"406": {"description": "Unsupported response content type"},
+						"415": {"description": "Unsupported request content type"},
+						"500": {"description": "Internal error"}
+					}
+				}
+			}
+		},
+		"components": {
+			"schemas": {
+				"openAPITestUser": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}
+			}
+		}
+	}`, string(actual))
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("GetUser", func(ctx context.Context, req *struct{}) (*openAPITestUser, error) { return nil, nil })
+	routes := NewRoutes().Method(http.MethodGet, "/user", "GetUser")
+
+	t.Run("default_path", func(t *testing.T) {
+		router, err := createRouter(ms, &Config{}, routes)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+DefaultOpenAPIPath, nil))
+		require.Equal(t, http.StatusOK, response.Code)
+
+		document := OpenAPI{}
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &document))
+		require.Equal(t, "0.0.0", document.Info.Version)
+		require.Contains(t, document.Paths, "/user")
+	})
+	t.Run("custom_path", func(t *testing.T) {
+		config := &Config{}
+		config.OpenAPI.Path = "/docs/api.json"
+		config.OpenAPI.Version = "2.0.0"
+		router, err := createRouter(ms, config, routes)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+"/docs/api.json", nil))
+		require.Equal(t, http.StatusOK, response.Code)
+
+		document := OpenAPI{}
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &document))
+		require.Equal(t, "2.0.0", document.Info.Version)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		config := &Config{}
+		config.OpenAPI.Path = "-"
+		router, err := createRouter(ms, config, routes)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url+DefaultOpenAPIPath, nil))
+		require.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestGenerateOpenAPIResponseBindings(t *testing.T) {
+	type CreateUserRes struct {
+		Status   int              `http:"status"`
+		Location string           `http:"header,location"`
+		Modified time.Time        `http:"header,Last-Modified"`
+		User     *openAPITestUser `http:"body"`
+	}
+	type DeleteUserRes struct {
+		Status int `http:"status"`
+	}
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("CreateUser", func(ctx context.Context, req *struct{}) (*CreateUserRes, error) { return nil, nil })
+	ms.AddMethod("DeleteUser", func(ctx context.Context, req *struct{}) (*DeleteUserRes, error) { return nil, nil })
+
+	routes := NewRoutes().Method(http.MethodPost, "/users", "CreateUser").Method(http.MethodDelete, "/users", "DeleteUser")
+	document, err := GenerateOpenAPI(ms, routes)
+	require.NoError(t, err)
+
+	actual, err := json.Marshal(document.Paths["/users"]["post"].Responses["2XX"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"description": "Successful response",
+		"headers": {
+			"Location": {"schema": {"type": "string"}},
+			"Last-Modified": {"schema": {"type": "string"}}
+		},
+		"content": {
+			"application/json": {"schema": {"anyOf": [{"$ref": "#/components/schemas/openAPITestUser"}, {"type": "null"}]}}
+		}
+	}`, string(actual))
+
+	actual, err = json.Marshal(document.Paths["/users"]["delete"].Responses["2XX"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"description": "Successful response"}`, string(actual))
+
+	// Requests without parameters or a body cannot be invalid
+	require.NotContains(t, document.Paths["/users"]["delete"].Responses, "400")
+}
@@ -1,10 +1,10 @@
 package http
 
 import (
-	"encoding/json"
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
 	"net/http"
+	neturl "net/url"
 	"reflect"
 	"strconv"
 	"strings"
@@ -16,15 +16,35 @@ type RequestDecoder interface {
 
 type requestDecoder struct {
 	targetType reflect.Type
+	bindings   []Binding
 	parsers    []func(*http.Request, reflect.Value) error
 }
 
-func newRequestDecoder(targetType reflect.Type) (*requestDecoder, error) {
+// Kinds of request struct field bindings.
+const (
+	BindingBody   = "body"
+	BindingQuery  = "query"
+	BindingPath   = "path"
+	BindingHeader = "header"
+	BindingCookie = "cookie"
+)
+
+// Describes how a request struct field is bound to a part of the HTTP request, as declared by its "http" tag. The name
+// is the name of the query parameter, path parameter, header or cookie, and is empty for the body.
+type Binding struct {
+	Kind  string
+	Name  string
+	Field reflect.StructField
+}
+
+// Parses the "http" tags of the given request struct type into bindings, in field order. Clients can use the bindings
+// to encode requests the same way the server decodes them.
+func ParseBindings(targetType reflect.Type) ([]Binding, error) {
 	if targetType.Kind() != reflect.Struct {
 		return nil, errors.Errorf("expected struct for request decoder target type; received '%s'", targetType.Kind())
 	}
 
-	parsers := make([]func(*http.Request, reflect.Value) error, 0, 10)
+	bindings := make([]Binding, 0, targetType.NumField())
 	for i := 0; i < targetType.NumField(); i++ {
 		fieldType := targetType.Field(i)
 
@@ -36,8 +56,8 @@ func newRequestDecoder(targetType reflect.Type) (*requestDecoder, error) {
 		tokens := strings.Split(tag, ",")
 		if len(tokens) == 0 || len(tokens) == 1 && strings.TrimSpace(tokens[0]) == "" {
 			return nil, errors.Errorf("illegal 'http' tag for field '%s': no tokens", fieldType.Name)
-		} else if len(tokens) == 1 && tokens[0] == "body" {
-			parsers = append(parsers, newBodyDecoder(fieldType))
+		} else if len(tokens) == 1 && tokens[0] == BindingBody {
+			bindings = append(bindings, Binding{Kind: BindingBody, Field: fieldType})
 		} else if len(tokens) > 2 {
 			return nil, errors.Errorf("illegal 'http' tag for field '%s': %s", fieldType.Name, tag)
 		} else {
@@ -45,26 +65,44 @@ func newRequestDecoder(targetType reflect.Type) (*requestDecoder, error) {
 				tokens = []string{tokens[0], strings.ToLower(fieldType.Name)}
 			}
 			switch tokens[0] {
-			case "query":
-				parsers = append(parsers, newQueryParameterDecoder(fieldType, tokens[1]))
-			case "path":
-				parsers = append(parsers, newPathParameterDecoder(fieldType, tokens[1]))
-			case "header":
-				parsers = append(parsers, newHeaderDecoder(fieldType, tokens[1]))
-			case "cookie":
-				parsers = append(parsers, newCookieDecoder(fieldType, tokens[1]))
+			case BindingQuery, BindingPath, BindingHeader, BindingCookie:
+				bindings = append(bindings, Binding{Kind: tokens[0], Name: tokens[1], Field: fieldType})
 			default:
 				return nil, errors.Errorf("illegal 'http' tag for field '%s': %s", fieldType.Name, tag)
 			}
 		}
 	}
-	return &requestDecoder{targetType, parsers}, nil
+	return bindings, nil
 }
 
-func (d *requestDecoder) Decode(r *http.Request) (interface{}, error) {
-	err := r.ParseForm()
+func newRequestDecoder(targetType reflect.Type) (*requestDecoder, error) {
+	bindings, err := ParseBindings(targetType)
 	if err != nil {
-		panic(errors.Wrapf(err, "failed parsing HTTP request form data"))
+		return nil, err
+	}
+
+	parsers := make([]func(*http.Request, reflect.Value) error, 0, len(bindings))
+	for _, b := range bindings {
+		switch b.Kind {
+		case BindingBody:
+			parsers = append(parsers, newBodyDecoder(b.Field))
+		case BindingQuery:
+			parsers = append(parsers, newQueryParameterDecoder(b.Field, b.Name))
+		case BindingPath:
+			parsers = append(parsers, newPathParameterDecoder(b.Field, b.Name))
+		case BindingHeader:
+			parsers = append(parsers, newHeaderDecoder(b.Field, b.Name))
+		case BindingCookie:
+			parsers = append(parsers, newCookieDecoder(b.Field, b.Name))
+		}
+	}
+	return &requestDecoder{targetType, bindings, parsers}, nil
+}
+
+func (d *requestDecoder) Decode(r *http.Request) (interface{}, error) {
+	// Parse the query string only; the body (even if form data) is left for the body decoder
+	if _, err := neturl.ParseQuery(r.URL.RawQuery); err != nil {
+		panic(errors.Wrapf(err, "failed parsing HTTP request query string"))
 	}
 
 	structValuePtr := reflect.New(d.targetType)
@@ -80,23 +118,21 @@ func (d *requestDecoder) Decode(r *http.Request) (interface{}, error) {
 
 func newBodyDecoder(field reflect.StructField) func(*http.Request, reflect.Value) error {
 	return func(r *http.Request, structValue reflect.Value) error {
-		switch contentType := r.Header.Get("content-type"); contentType {
-		case "application/json":
-			newValuePtr := reflect.New(field.Type)
-			if r.ContentLength == 0 {
-				structValue.FieldByIndex(field.Index).Set(reflect.Zero(field.Type))
-			} else {
-				jsonDecoder := json.NewDecoder(r.Body)
-				jsonDecoder.DisallowUnknownFields()
-				if err := jsonDecoder.Decode(newValuePtr.Interface()); err != nil {
-					return errors.Wrapf(err, "failed reading JSON into '%s'", field.Type.Name())
-				}
-				structValue.FieldByIndex(field.Index).Set(newValuePtr.Elem())
-			}
+		decoder, mediaType, err := resolveDecoder(r.Header.Get("content-type"))
+		if err != nil {
+			return err
+		}
+
+		if r.ContentLength == 0 {
+			structValue.FieldByIndex(field.Index).Set(reflect.Zero(field.Type))
 			return nil
-		default:
-			return NewHttpError(http.StatusUnsupportedMediaType, errors.Errorf("'%s' is not supported", contentType))
 		}
+		newValuePtr := reflect.New(field.Type)
+		if err := decoder.Decode(r.Body, newValuePtr.Interface()); err != nil {
+			return errors.Wrapf(err, "failed reading '%s' into '%s'", mediaType, field.Type.Name())
+		}
+		structValue.FieldByIndex(field.Index).Set(newValuePtr.Elem())
+		return nil
 	}
 }
 
@@ -155,7 +191,7 @@ func newQueryParameterDecoder(field reflect.StructField, name string) func(*http
 		}
 	}
 	return func(r *http.Request, structValue reflect.Value) error {
-		values, ok := r.Form[name]
+		values, ok := r.URL.Query()[name]
 		if !ok {
 			values = nil
 		}
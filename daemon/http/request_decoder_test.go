@@ -140,7 +140,7 @@ func TestRequestDecoder(t *testing.T) {
 				request := httptest.NewRequest(http.MethodGet, url, strings.NewReader(`"unterminated`))
 				request.Header.Add("content-type", "application/json")
 				_, err = decoder.Decode(request)
-				require.EqualError(t, err, "failed parsing request: failed reading JSON into 'string': unexpected EOF")
+				require.EqualError(t, err, "failed parsing request: failed reading 'application/json' into 'string': unexpected EOF")
 			})
 		})
 		t.Run("unsupported_media_type", func(t *testing.T) {
@@ -2,14 +2,21 @@ package http
 
 import (
 	"bytes"
-	"encoding/json"
+	"fmt"
 	"github.com/arikkfir/msvc"
 	"github.com/pkg/errors"
 	"io"
 	"net/http"
 	"reflect"
+	"strings"
+	"time"
 )
 
+// Kind of response struct field bindings to the HTTP status code.
+const BindingStatus = "status"
+
+var cookieType = reflect.TypeOf(http.Cookie{})
+
 type ResponseEncoder interface {
 	MarshallServiceResponse(interface{}, *http.Request, http.ResponseWriter)
 	MarshallServiceResponseAndError(interface{}, error, *http.Request, http.ResponseWriter)
@@ -17,25 +24,196 @@ type ResponseEncoder interface {
 
 type responseEncoder struct {
 	sourceType reflect.Type
+	bindings   []Binding
+}
+
+// A service response split into the parts of the HTTP response its fields are bound to.
+type splitResponse struct {
+	status  int
+	header  http.Header
+	cookies []*http.Cookie
+	body    interface{}
+}
+
+// Parses the "http" tags of the given response struct type into bindings, in field order. Response structs without any
+// "http" tags have no bindings (nil), and are encoded as the response body as a whole; otherwise, every field must be
+// bound to the status code ("status"), a header ("header,<name>"), a cookie ("cookie,<name>") or the body ("body").
+func ParseResponseBindings(sourceType reflect.Type) ([]Binding, error) {
+	if sourceType.Kind() == reflect.Ptr {
+		sourceType = sourceType.Elem()
+	}
+	if sourceType.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	tagged := false
+	for i := 0; i < sourceType.NumField(); i++ {
+		if _, ok := sourceType.Field(i).Tag.Lookup("http"); ok {
+			tagged = true
+		}
+	}
+	if !tagged {
+		return nil, nil
+	}
+
+	bindings := make([]Binding, 0, sourceType.NumField())
+	bound := make(map[string]string)
+	for i := 0; i < sourceType.NumField(); i++ {
+		fieldType := sourceType.Field(i)
+
+		tag, ok := fieldType.Tag.Lookup("http")
+		if !ok {
+			return nil, errors.Errorf("missing 'http' tag for field '%s'", fieldType.Name)
+		}
+
+		tokens := strings.Split(tag, ",")
+		var b Binding
+		switch {
+		case len(tokens) == 1 && (tokens[0] == BindingBody || tokens[0] == BindingStatus):
+			b = Binding{Kind: tokens[0], Field: fieldType}
+		case len(tokens) <= 2 && (tokens[0] == BindingHeader || tokens[0] == BindingCookie):
+			if len(tokens) == 1 {
+				tokens = append(tokens, strings.ToLower(fieldType.Name))
+			}
+			b = Binding{Kind: tokens[0], Name: tokens[1], Field: fieldType}
+		default:
+			return nil, errors.Errorf("illegal 'http' tag for field '%s': %s", fieldType.Name, tag)
+		}
+
+		if err := validateResponseBinding(b); err != nil {
+			return nil, err
+		}
+		key := b.Kind + ":" + b.Name
+		if b.Kind == BindingHeader {
+			key = b.Kind + ":" + http.CanonicalHeaderKey(b.Name)
+		}
+		if other, ok := bound[key]; ok {
+			return nil, errors.Errorf("fields '%s' and '%s' are both bound to '%s'", other, fieldType.Name, tag)
+		}
+		bound[key] = fieldType.Name
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// Validates that the type of the given response binding's field can be written to its part of the HTTP response.
+func validateResponseBinding(b Binding) error {
+	fieldType := b.Field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	switch b.Kind {
+	case BindingStatus:
+		switch fieldType.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+		return errors.Errorf("field '%s' is bound to the status code, but is not an integer", b.Field.Name)
+	case BindingHeader:
+		if fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType == timeType || isScalarKind(fieldType.Kind()) {
+			return nil
+		}
+		return errors.Errorf("writing headers from fields of type '%s' is not supported (field '%s')", b.Field.Type, b.Field.Name)
+	case BindingCookie:
+		if fieldType == cookieType || isScalarKind(fieldType.Kind()) {
+			return nil
+		}
+		return errors.Errorf("writing cookies from fields of type '%s' is not supported (field '%s')", b.Field.Type, b.Field.Name)
+	default:
+		return nil
+	}
+}
+
+// Returns the type of the response body of the given response struct type, or nil if it has no body.
+func responseBodyType(sourceType reflect.Type) reflect.Type {
+	bindings, err := ParseResponseBindings(sourceType)
+	if err != nil || bindings == nil {
+		return sourceType
+	}
+	for _, b := range bindings {
+		if b.Kind == BindingBody {
+			return b.Field.Type
+		}
+	}
+	return nil
 }
 
 func newResponseEncoder(sourceType reflect.Type) (*responseEncoder, error) {
-	return &responseEncoder{sourceType}, nil
+	bindings, err := ParseResponseBindings(sourceType)
+	if err != nil {
+		return nil, err
+	}
+	return &responseEncoder{sourceType, bindings}, nil
+}
+
+// Splits the given (non-nil) service response into the parts of the HTTP response its fields are bound to.
+func (h *responseEncoder) split(serviceResponse interface{}) *splitResponse {
+	response := &splitResponse{header: make(http.Header)}
+	if h.bindings == nil {
+		response.body = serviceResponse
+		return response
+	}
+
+	structValue := reflect.Indirect(reflect.ValueOf(serviceResponse))
+	for _, b := range h.bindings {
+		fieldValue := structValue.FieldByIndex(b.Field.Index)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				continue
+			}
+			if b.Kind != BindingBody {
+				fieldValue = fieldValue.Elem()
+			}
+		}
+		switch b.Kind {
+		case BindingStatus:
+			if fieldValue.Kind() >= reflect.Uint && fieldValue.Kind() <= reflect.Uint64 {
+				response.status = int(fieldValue.Uint())
+			} else {
+				response.status = int(fieldValue.Int())
+			}
+		case BindingHeader:
+			for _, value := range formatHeaderValues(fieldValue) {
+				response.header.Add(b.Name, value)
+			}
+		case BindingCookie:
+			if fieldValue.Type() == cookieType {
+				cookie := fieldValue.Interface().(http.Cookie)
+				if cookie.Name == "" {
+					cookie.Name = b.Name
+				}
+				response.cookies = append(response.cookies, &cookie)
+			} else {
+				response.cookies = append(response.cookies, &http.Cookie{Name: b.Name, Value: fmt.Sprint(fieldValue.Interface())})
+			}
+		case BindingBody:
+			if fieldValue.Kind() != reflect.Interface || !fieldValue.IsNil() {
+				response.body = fieldValue.Interface()
+			}
+		}
+	}
+	return response
+}
+
+// Writes the headers & cookies of the given response.
+func (r *splitResponse) writeHeaders(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	for _, cookie := range r.cookies {
+		http.SetCookie(w, cookie)
+	}
 }
 
 func (h *responseEncoder) marshallServiceResponse(ms *msvc.MicroService, serviceResponse interface{}, mediaType string, w io.Writer) error {
 	if serviceResponse != nil {
-		switch mediaType {
-		case "application/json":
-			encoder := json.NewEncoder(w)
-			encoder.SetEscapeHTML(false)
-			if ms == nil || ms.Environment() != msvc.EnvProduction {
-				encoder.SetIndent("", "  ")
-			}
-			return encoder.Encode(serviceResponse)
-		default:
+		encoder := encoderFor(mediaType)
+		if encoder == nil {
 			return NewHttpError(http.StatusNotAcceptable, errors.Errorf("'%s' is not supported", mediaType))
 		}
+		return encoder.Encode(w, serviceResponse, ms == nil || ms.Environment() != msvc.EnvProduction)
 	}
 	return nil
 }
@@ -47,13 +225,38 @@ func (h *responseEncoder) MarshallServiceResponse(serviceResponse interface{}, r
 	}
 
 	ms := msvc.GetFromContext(r.Context())
-	mediaType := r.Header.Get("accept")
+	response := h.split(serviceResponse)
+	if response.status == 0 {
+		response.status = http.StatusOK
+	}
+
+	// Responses without a body (or with statuses that must not have one) only have headers
+	if response.body == nil || response.status == http.StatusNoContent || response.status == http.StatusNotModified {
+		response.writeHeaders(w)
+		w.WriteHeader(response.status)
+		return
+	}
+
+	// Stream iterator bodies incrementally, instead of buffering them
+	if iterator, ok := response.body.(Iterator); ok {
+		h.streamServiceResponse(response, iterator, r, w)
+		return
+	}
+
+	mediaType, ok := negotiateMediaType(r)
+	if !ok {
+		if ms != nil {
+			ms.Log("res", serviceResponse, "err", errors.Errorf("none of '%s' is supported", r.Header.Get("accept")))
+		}
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
 
 	// Marshall service response into an in-memory buffer
 	buffer := new(bytes.Buffer)
-	if err := h.marshallServiceResponse(ms, serviceResponse, mediaType, buffer); err != nil {
+	if err := h.marshallServiceResponse(ms, response.body, mediaType, buffer); err != nil {
 		if ms != nil {
-			ms.Log("err", err, "msg", "failed encoding JSON")
+			ms.Log("err", err, "msg", "failed encoding response")
 		}
 		if httpErr, ok := err.(ErrHttp); ok {
 			err = httpErr.Cause()
@@ -71,10 +274,11 @@ func (h *responseEncoder) MarshallServiceResponse(serviceResponse interface{}, r
 	}
 
 	// Write buffer back to client
-	w.Header().Set("Content-Type", mediaType)
-	w.WriteHeader(http.StatusOK)
+	response.writeHeaders(w)
+	w.Header().Set("Content-Type", contentTypeOf(mediaType))
+	w.WriteHeader(response.status)
 	if _, err := buffer.WriteTo(w); err != nil && ms != nil {
-		ms.Log("err", err, "msg", "failed serializing JSON buffer to response")
+		ms.Log("err", err, "msg", "failed serializing response buffer")
 	}
 }
 
@@ -97,16 +301,61 @@ func (h *responseEncoder) MarshallServiceResponseAndError(serviceResponse interf
 		httpStatusCode = http.StatusInternalServerError
 	}
 
-	// Write HTTP status code; if we have a service response, add "content-type" header too (must be done before writing HTTP status code)
+	// Write headers & cookies of the service response, if any (the error's status code takes precedence over its own)
+	var body interface{}
 	if serviceResponse != nil {
-		w.Header().Set("content-type", r.Header.Get("accept"))
+		response := h.split(serviceResponse)
+		response.writeHeaders(w)
+		body = response.body
+	}
+
+	// Write HTTP status code; if we have a response body in an acceptable media type, add "content-type" header too
+	// (must be done before writing HTTP status code)
+	mediaType, acceptable := negotiateMediaType(r)
+	if body != nil && acceptable {
+		w.Header().Set("content-type", contentTypeOf(mediaType))
 	}
 	w.WriteHeader(httpStatusCode)
 
-	// Marshall service response into an in-memory buffer, if any
-	if serviceResponse != nil {
-		if err := h.marshallServiceResponse(ms, serviceResponse, r.Header.Get("accept"), w); err != nil && ms != nil {
+	// Marshall response body, if any
+	if body != nil && acceptable {
+		if err := h.marshallServiceResponse(ms, body, mediaType, w); err != nil && ms != nil {
 			ms.Log("res", serviceResponse, "err", err)
 		}
 	}
 }
+
+// Formats the given header field value as header values; empty strings have no values, and times are formatted as
+// HTTP dates.
+func formatHeaderValues(value reflect.Value) []string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch {
+	case value.Type() == timeType:
+		return []string{value.Interface().(time.Time).UTC().Format(http.TimeFormat)}
+	case value.Kind() == reflect.Slice:
+		values := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, formatHeaderValues(value.Index(i))...)
+		}
+		return values
+	case value.Kind() == reflect.String && value.Len() == 0:
+		return nil
+	default:
+		return []string{fmt.Sprint(value.Interface())}
+	}
+}
+
+// Returns whether the given kind is a scalar kind (boolean, number or string).
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestResponseEncoder(t *testing.T) {
@@ -18,6 +19,18 @@ func TestResponseEncoder(t *testing.T) {
 			"Hello",
 			httptest.NewRequest(http.MethodGet, url, nil),
 			response)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "application/json; charset=utf-8", response.Header().Get("content-type"))
+		require.Equal(t, response.Body.String(), "\"Hello\"\n")
+	})
+	t.Run("accept_unsupported", func(t *testing.T) {
+		encoder, err := newResponseEncoder(reflect.TypeOf(""))
+		require.NoError(t, err)
+
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		request.Header.Add("accept", "text/plain, application/json;q=0")
+		encoder.MarshallServiceResponse("Hello", request, response)
 		require.Equal(t, http.StatusNotAcceptable, response.Code)
 		require.Equal(t, response.Body.String(), "")
 	})
@@ -46,3 +59,105 @@ func TestResponseEncoder(t *testing.T) {
 		require.Equal(t, response.Body.String(), "{\n  \"p\": \"Hello\"\n}\n")
 	})
 }
+
+func TestResponseBindings(t *testing.T) {
+	type Body struct {
+		Name string `json:"name"`
+	}
+	type Resp struct {
+		Status   int          `http:"status"`
+		Location string       `http:"header,Location"`
+		Vary     []string     `http:"header,vary"`
+		Modified *time.Time   `http:"header,Last-Modified"`
+		Session  string       `http:"cookie,session"`
+		Tracking *http.Cookie `http:"cookie,tracking"`
+		Body     *Body        `http:"body"`
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		bindings, err := ParseResponseBindings(reflect.TypeOf(Resp{}))
+		require.NoError(t, err)
+		require.Len(t, bindings, 7)
+		require.Equal(t, BindingStatus, bindings[0].Kind)
+		require.Equal(t, "Location", bindings[1].Name)
+
+		bindings, err = ParseResponseBindings(reflect.TypeOf(Body{}))
+		require.NoError(t, err)
+		require.Nil(t, bindings)
+
+		_, err = ParseResponseBindings(reflect.TypeOf(struct {
+			Status string `http:"status"`
+		}{}))
+		require.EqualError(t, err, "field 'Status' is bound to the status code, but is not an integer")
+		_, err = ParseResponseBindings(reflect.TypeOf(struct {
+			A string `http:"header,etag"`
+			B string `http:"header,ETag"`
+		}{}))
+		require.EqualError(t, err, "fields 'A' and 'B' are both bound to 'header,ETag'")
+		_, err = ParseResponseBindings(reflect.TypeOf(struct {
+			A string `http:"query,a"`
+		}{}))
+		require.EqualError(t, err, "illegal 'http' tag for field 'A': query,a")
+		_, err = ParseResponseBindings(reflect.TypeOf(struct {
+			A string `http:"body"`
+			B string
+		}{}))
+		require.EqualError(t, err, "missing 'http' tag for field 'B'")
+		_, err = newResponseEncoder(reflect.TypeOf(struct {
+			A map[string]string `http:"header,a"`
+		}{}))
+		require.EqualError(t, err, "writing headers from fields of type 'map[string]string' is not supported (field 'A')")
+	})
+	t.Run("encode", func(t *testing.T) {
+		encoder, err := newResponseEncoder(reflect.TypeOf(Resp{}))
+		require.NoError(t, err)
+
+		modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		response := httptest.NewRecorder()
+		encoder.MarshallServiceResponse(&Resp{
+			Status:   http.StatusCreated,
+			Location: "/users/1",
+			Vary:     []string{"Accept", "Cookie"},
+			Modified: &modified,
+			Session:  "abc",
+			Tracking: &http.Cookie{Value: "xyz", HttpOnly: true},
+			Body:     &Body{Name: "Jack"},
+		}, httptest.NewRequest(http.MethodPost, url, nil), response)
+		require.Equal(t, http.StatusCreated, response.Code)
+		require.Equal(t, "/users/1", response.Header().Get("Location"))
+		require.Equal(t, []string{"Accept", "Cookie"}, response.Header()["Vary"])
+		require.Equal(t, "Thu, 02 Jan 2020 03:04:05 GMT", response.Header().Get("Last-Modified"))
+		require.Equal(t, []string{"session=abc", "tracking=xyz; HttpOnly"}, response.Header()["Set-Cookie"])
+		require.JSONEq(t, `{"name": "Jack"}`, response.Body.String())
+	})
+	t.Run("no_body", func(t *testing.T) {
+		encoder, err := newResponseEncoder(reflect.TypeOf(Resp{}))
+		require.NoError(t, err)
+
+		response := httptest.NewRecorder()
+		encoder.MarshallServiceResponse(&Resp{Status: http.StatusAccepted, Location: "/jobs/1"}, httptest.NewRequest(http.MethodPost, url, nil), response)
+		require.Equal(t, http.StatusAccepted, response.Code)
+		require.Equal(t, "/jobs/1", response.Header().Get("Location"))
+		require.Equal(t, "", response.Header().Get("Content-Type"))
+		require.Equal(t, "", response.Body.String())
+
+		response = httptest.NewRecorder()
+		encoder.MarshallServiceResponse(&Resp{Status: http.StatusNoContent, Body: &Body{Name: "ignored"}}, httptest.NewRequest(http.MethodPost, url, nil), response)
+		require.Equal(t, http.StatusNoContent, response.Code)
+		require.Equal(t, "", response.Body.String())
+	})
+	t.Run("error", func(t *testing.T) {
+		encoder, err := newResponseEncoder(reflect.TypeOf(Resp{}))
+		require.NoError(t, err)
+
+		response := httptest.NewRecorder()
+		encoder.MarshallServiceResponseAndError(
+			&Resp{Status: http.StatusOK, Location: "/users/1", Body: &Body{Name: "Jack"}},
+			NewHttpError(http.StatusConflict, nil),
+			httptest.NewRequest(http.MethodPost, url, nil),
+			response)
+		require.Equal(t, http.StatusConflict, response.Code)
+		require.Equal(t, "/users/1", response.Header().Get("Location"))
+		require.JSONEq(t, `{"name": "Jack"}`, response.Body.String())
+	})
+}
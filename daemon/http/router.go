@@ -1,17 +1,35 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/arikkfir/msvc"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/pkg/errors"
 	"github.com/rs/cors"
 	"net/http"
-	"strings"
 )
 
-func createRouter(ms *msvc.MicroService, corsHost string, corsPort uint16, handlers map[string]interface{}) chi.Router {
+// Creates an HTTP handler serving the given routes, along with the health, OpenAPI & JSON schema endpoints, or returns
+// all configuration errors found in the routes. This allows serving the routes from custom servers (or tests).
+func NewRouter(ms *msvc.MicroService, config *Config, routes *Routes) (http.Handler, error) {
+	return createRouter(ms, config, routes)
+}
+
+func createRouter(ms *msvc.MicroService, config *Config, routes *Routes) (chi.Router, error) {
+	resolvedRoutes, err := routes.resolve(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	// Server-sent event stream settings
+	eventStreams := eventStreamSettings{config.SSE.Retry, config.SSE.KeepAlive}
+	if eventStreams.retry <= 0 {
+		eventStreams.retry = DefaultEventStreamRetry
+	}
+	if eventStreams.keepAlive <= 0 {
+		eventStreams.keepAlive = DefaultEventStreamKeepAlive
+	}
 
 	// Create router
 	router := chi.NewRouter()
@@ -24,17 +42,15 @@ func createRouter(ms *msvc.MicroService, corsHost string, corsPort uint16, handl
 			})
 		},
 
-		// First provide a "/health" endpoint
-		middleware.Heartbeat("/health"),
-
 		// Ensure request is uniquely identified & logged (with the real user IP)
 		middleware.RequestID,
 		middleware.RealIP,
 
-		// Provide micro-service
+		// Provide micro-service & server-sent event stream settings
 		func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				next.ServeHTTP(w, r.WithContext(msvc.SetInContext(r.Context(), ms)))
+				ctx := msvc.SetInContext(r.Context(), ms)
+				next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, eventStreamContextKey{}, eventStreams)))
 			})
 		},
 
@@ -65,14 +81,13 @@ func createRouter(ms *msvc.MicroService, corsHost string, corsPort uint16, handl
 
 		// Apply common headers
 		middleware.NoCache,
-		middleware.AllowContentType("application/json", ""),
-		middleware.ContentCharset("", "UTF-8"),
+		allowDecodableContentTypes,
 	)
 
 	//  Add CORS if specified in configuration
-	if corsHost != "" && corsPort != 0 {
+	if config.CORS.Host != "" && config.CORS.Port != 0 {
 		router.Use(cors.New(cors.Options{
-			AllowedOrigins:   []string{fmt.Sprintf("http://%s:%d", corsHost, corsPort)},
+			AllowedOrigins:   []string{fmt.Sprintf("http://%s:%d", config.CORS.Host, config.CORS.Port)},
 			AllowedMethods:   []string{"OPTIONS", "HEAD", "GET", "POST", "PATCH", "PUT", "DELETE"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 			ExposedHeaders:   []string{"Link"},
@@ -81,46 +96,25 @@ func createRouter(ms *msvc.MicroService, corsHost string, corsPort uint16, handl
 		}).Handler)
 	}
 
-	// Register handlers
-	for k, v := range handlers {
-		mountRoutes(router, k, v)
-	}
-
-	return router
-}
-
-func mountRoutes(router chi.Router, entryKey string, entryValue interface{}) {
-	upperCaseEntryKey := strings.ToUpper(entryKey)
-	if routesMap, ok := entryValue.(map[string]interface{}); ok {
-		if entryKey[0] != '/' {
-			entryKey = "/" + entryKey
+	// Register health, OpenAPI & JSON schema endpoints, and handlers
+	mountHealthEndpoints(router, ms)
+	if openAPIPath := config.OpenAPI.Path; openAPIPath != "-" {
+		if openAPIPath == "" {
+			openAPIPath = DefaultOpenAPIPath
 		}
-		router.Route(entryKey, func(r chi.Router) {
-			for k, v := range routesMap {
-				mountRoutes(r, k, v)
-			}
-		})
-	} else if upperCaseEntryKey == http.MethodGet ||
-		upperCaseEntryKey == http.MethodHead ||
-		upperCaseEntryKey == http.MethodPost ||
-		upperCaseEntryKey == http.MethodPut ||
-		upperCaseEntryKey == http.MethodPatch ||
-		upperCaseEntryKey == http.MethodDelete ||
-		upperCaseEntryKey == http.MethodConnect ||
-		upperCaseEntryKey == http.MethodOptions ||
-		upperCaseEntryKey == http.MethodTrace {
-
-		if handler, ok := entryValue.(Handler); ok {
-			router.MethodFunc(strings.ToUpper(entryKey), "/", handler.Handle)
-		} else {
-			panic(errors.Errorf("bad routes map in '%s: %+v'", entryKey, entryValue))
+		var options []OpenAPIOption
+		if config.OpenAPI.Version != "" {
+			options = append(options, WithOpenAPIVersion(config.OpenAPI.Version))
 		}
-	} else if handler, ok := entryValue.(Handler); ok {
-		if entryKey[0] != '/' {
-			entryKey = "/" + entryKey
+		mountOpenAPIEndpoint(router, ms, openAPIPath, generateOpenAPI(ms, resolvedRoutes, options...))
+	}
+	if schemasPath := config.Schemas.Path; schemasPath != "-" {
+		if schemasPath == "" {
+			schemasPath = DefaultSchemasPath
 		}
-		router.HandleFunc(entryKey, handler.Handle)
-	} else {
-		panic(errors.Errorf("bad routes map in '%s: %+v'", entryKey, entryValue))
+		mountSchemaEndpoints(router, ms, schemasPath)
 	}
+	mountResolvedRoutes(router, resolvedRoutes)
+
+	return router, nil
 }
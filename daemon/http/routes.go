@@ -0,0 +1,344 @@
+package http
+
+import (
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var httpMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+type RouteOption func(*routeOptions)
+
+type routeOptions struct {
+	middlewares []func(http.Handler) http.Handler
+}
+
+// Wraps the route (or all routes of the group) with the given HTTP middleware. Middleware of a group wraps the middleware
+// of its nested groups & routes.
+func WithHTTPMiddleware(middlewares ...func(http.Handler) http.Handler) RouteOption {
+	return func(options *routeOptions) {
+		options.middlewares = append(options.middlewares, middlewares...)
+	}
+}
+
+// Routes defines the routes served by an HTTP server, as a tree of groups, each with its own path prefix & options.
+// Routes are registered in the order they were defined, and all configuration errors are reported together when the
+// server is created.
+type Routes struct {
+	prefix          string
+	options         []RouteOption
+	entries         []routesEntry
+	allowAllUnbound bool
+	allowedUnbound  []string
+}
+
+// A single entry of a routes group; exactly one of its fields is set.
+type routesEntry struct {
+	route        *routeDefinition
+	group        *Routes
+	conventional *ConventionalRoutes
+	err          error
+}
+
+type routeDefinition struct {
+	httpMethod string
+	pattern    string
+	handler    Handler
+	methodName string
+	options    []RouteOption
+}
+
+// A route resolved from its definition, with its full pattern & all middleware that apply to it.
+type resolvedRoute struct {
+	httpMethod  string
+	pattern     string
+	handler     Handler
+	descriptor  *msvc.MethodDescriptor
+	middlewares []func(http.Handler) http.Handler
+}
+
+func (r *resolvedRoute) String() string {
+	httpMethod := r.httpMethod
+	if httpMethod == "" {
+		httpMethod = "*"
+	}
+	return httpMethod + " " + r.pattern
+}
+
+// Creates an empty routes tree; the given options apply to all routes.
+func NewRoutes(options ...RouteOption) *Routes {
+	return &Routes{options: options}
+}
+
+// Creates routes from a (possibly nested) routes map, as accepted by NewHTTPServer. Map keys are either HTTP methods
+// (mapped to handlers) or paths (mapped to handlers serving all HTTP methods, to conventional routes, or to nested
+// maps). Entries are registered in the order of their keys. For compatibility with services written before routes were
+// validated, methods are allowed not to be bound to any route (eg. when served by other daemons).
+func RoutesFromMap(handlers map[string]interface{}) *Routes {
+	routes := NewRoutes().AllowUnboundMethods()
+	routes.addMap(handlers)
+	return routes
+}
+
+func (r *Routes) addMap(handlers map[string]interface{}) {
+	keys := make([]string, 0, len(handlers))
+	for key := range handlers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch value := handlers[key].(type) {
+		case *ConventionalRoutes:
+			r.Group(key).Conventional(value)
+		case map[string]interface{}:
+			r.Group(key).addMap(value)
+		case Handler:
+			if upperCaseKey := strings.ToUpper(key); httpMethods[upperCaseKey] {
+				r.Handle(upperCaseKey, "/", value)
+			} else {
+				r.Handle("", key, value)
+			}
+		default:
+			r.entries = append(r.entries, routesEntry{err: errors.Errorf("bad routes map in '%s: %+v'", key, value)})
+		}
+	}
+}
+
+// Adds a nested group of routes under the given path prefix, returning it.
+func (r *Routes) Group(prefix string, options ...RouteOption) *Routes {
+	group := &Routes{prefix: prefix, options: options}
+	r.entries = append(r.entries, routesEntry{group: group})
+	return group
+}
+
+// Routes the given HTTP method & pattern to the given handler. An empty HTTP method routes all HTTP methods.
+func (r *Routes) Handle(httpMethod, pattern string, handler Handler, options ...RouteOption) *Routes {
+	route := &routeDefinition{httpMethod: httpMethod, pattern: pattern, handler: handler, options: options}
+	r.entries = append(r.entries, routesEntry{route: route})
+	return r
+}
+
+// Routes the given HTTP method & pattern to the named micro-service method.
+func (r *Routes) Method(httpMethod, pattern, methodName string, options ...RouteOption) *Routes {
+	route := &routeDefinition{httpMethod: httpMethod, pattern: pattern, methodName: methodName, options: options}
+	r.entries = append(r.entries, routesEntry{route: route})
+	return r
+}
+
+func (r *Routes) Get(pattern string, handler Handler, options ...RouteOption) *Routes {
+	return r.Handle(http.MethodGet, pattern, handler, options...)
+}
+
+func (r *Routes) Post(pattern string, handler Handler, options ...RouteOption) *Routes {
+	return r.Handle(http.MethodPost, pattern, handler, options...)
+}
+
+func (r *Routes) Put(pattern string, handler Handler, options ...RouteOption) *Routes {
+	return r.Handle(http.MethodPut, pattern, handler, options...)
+}
+
+func (r *Routes) Patch(pattern string, handler Handler, options ...RouteOption) *Routes {
+	return r.Handle(http.MethodPatch, pattern, handler, options...)
+}
+
+func (r *Routes) Delete(pattern string, handler Handler, options ...RouteOption) *Routes {
+	return r.Handle(http.MethodDelete, pattern, handler, options...)
+}
+
+// Routes WebSocket connections on the given pattern, over which clients can call any method (see
+// NewWebSocketHandler). Methods are considered bound to this route.
+func (r *Routes) WebSocket(pattern string, options ...WebSocketOption) *Routes {
+	return r.Get(pattern, NewWebSocketHandler(options...))
+}
+
+// Adds the given conventional routes under this group's prefix.
+func (r *Routes) Conventional(routes *ConventionalRoutes) *Routes {
+	r.entries = append(r.entries, routesEntry{conventional: routes})
+	return r
+}
+
+// Allows the given methods (or all methods, if none are given) not to be bound to any route, eg. because they are
+// served by another daemon. Otherwise, methods not bound to any route fail the routes validation.
+func (r *Routes) AllowUnboundMethods(methodNames ...string) *Routes {
+	if len(methodNames) == 0 {
+		r.allowAllUnbound = true
+	}
+	r.allowedUnbound = append(r.allowedUnbound, methodNames...)
+	return r
+}
+
+// Collects resolved routes & configuration problems while walking the routes tree.
+type routesResolver struct {
+	ms              *msvc.MicroService
+	routes          []*resolvedRoute
+	problems        []string
+	allowAllUnbound bool
+	allowedUnbound  map[string]bool
+}
+
+func (resolver *routesResolver) addProblem(format string, args ...interface{}) {
+	problem := fmt.Sprintf(format, args...)
+	for _, existing := range resolver.problems {
+		if existing == problem {
+			return
+		}
+	}
+	resolver.problems = append(resolver.problems, problem)
+}
+
+// Resolves all routes in definition order and validates them, returning all configuration errors found at once.
+func (r *Routes) resolve(ms *msvc.MicroService) ([]*resolvedRoute, error) {
+	resolver := &routesResolver{ms: ms, routes: make([]*resolvedRoute, 0), allowedUnbound: make(map[string]bool)}
+	r.resolveInto(resolver, "", nil)
+
+	seen := make(map[string]bool)
+	for _, route := range resolver.routes {
+		if seen[route.String()] {
+			resolver.addProblem("duplicate route '%s'", route)
+		}
+		seen[route.String()] = true
+	}
+	resolver.validate()
+
+	if len(resolver.problems) > 0 {
+		return nil, errors.Errorf("invalid HTTP routes:\n%s", strings.Join(resolver.problems, "\n"))
+	}
+	return resolver.routes, nil
+}
+
+func (r *Routes) resolveInto(resolver *routesResolver, prefix string, middlewares []func(http.Handler) http.Handler) {
+	prefix = joinPattern(prefix, r.prefix)
+	middlewares = appendRouteMiddlewares(middlewares, r.options)
+	resolver.allowAllUnbound = resolver.allowAllUnbound || r.allowAllUnbound
+	for _, name := range r.allowedUnbound {
+		resolver.allowedUnbound[name] = true
+	}
+
+	for _, entry := range r.entries {
+		switch {
+		case entry.err != nil:
+			resolver.addProblem("%s", entry.err.Error())
+		case entry.group != nil:
+			entry.group.resolveInto(resolver, prefix, middlewares)
+		case entry.conventional != nil:
+			conventionalRoutes, err := entry.conventional.routes()
+			if err != nil {
+				resolver.addProblem("%s", errors.Wrapf(err, "bad conventional routes under '%s'", prefix).Error())
+				continue
+			}
+			for _, conventionalRoute := range conventionalRoutes {
+				h, err := newMethodHandler(resolver.ms, conventionalRoute.descriptor)
+				if err != nil {
+					resolver.addProblem("%s", errors.Wrapf(err, "bad handler for method '%s'", conventionalRoute.descriptor.Name).Error())
+					continue
+				}
+				resolver.routes = append(resolver.routes, &resolvedRoute{
+					httpMethod:  conventionalRoute.httpMethod,
+					pattern:     joinPattern(prefix, conventionalRoute.path),
+					handler:     h,
+					descriptor:  conventionalRoute.descriptor,
+					middlewares: middlewares,
+				})
+			}
+		default:
+			route := &resolvedRoute{
+				httpMethod:  strings.ToUpper(entry.route.httpMethod),
+				pattern:     joinPattern(prefix, entry.route.pattern),
+				handler:     entry.route.handler,
+				middlewares: appendRouteMiddlewares(middlewares, entry.route.options),
+			}
+			if route.httpMethod != "" && !httpMethods[route.httpMethod] {
+				resolver.addProblem("unknown HTTP method in route '%s'", route)
+				continue
+			}
+			if entry.route.methodName != "" {
+				descriptor := resolver.ms.GetMethodDescriptor(entry.route.methodName)
+				if descriptor == nil {
+					resolver.addProblem("route '%s' refers to unknown method '%s'", route, entry.route.methodName)
+					continue
+				}
+				h, err := newMethodHandler(resolver.ms, descriptor)
+				if err != nil {
+					resolver.addProblem("%s", errors.Wrapf(err, "bad handler for method '%s'", descriptor.Name).Error())
+					continue
+				}
+				route.handler, route.descriptor = h, descriptor
+			} else if route.handler == nil {
+				resolver.addProblem("nil handler for route '%s'", route)
+				continue
+			} else if h, ok := route.handler.(*handler); ok {
+				route.descriptor = resolver.descriptorOf(h.methodAdapter)
+			}
+			resolver.routes = append(resolver.routes, route)
+		}
+	}
+}
+
+// Returns the descriptor of the registered method with the given adapter, if any.
+func (resolver *routesResolver) descriptorOf(adapter msvc.MethodAdapter) *msvc.MethodDescriptor {
+	for _, descriptor := range resolver.ms.Methods() {
+		if descriptor.Adapter == adapter {
+			return descriptor
+		}
+	}
+	return nil
+}
+
+// Registers the resolved routes in the given router, in order.
+func mountResolvedRoutes(router chi.Router, routes []*resolvedRoute) {
+	for _, route := range routes {
+		r := router
+		if len(route.middlewares) > 0 {
+			r = router.With(route.middlewares...)
+		}
+		if route.httpMethod == "" {
+			r.HandleFunc(route.pattern, route.handler.Handle)
+		} else {
+			r.MethodFunc(route.httpMethod, route.pattern, route.handler.Handle)
+		}
+	}
+}
+
+// Returns a new slice with the given middleware, followed by the middleware provided by the given options.
+func appendRouteMiddlewares(middlewares []func(http.Handler) http.Handler, options []RouteOption) []func(http.Handler) http.Handler {
+	opts := &routeOptions{middlewares: append([]func(http.Handler) http.Handler{}, middlewares...)}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts.middlewares
+}
+
+// Joins a path prefix & pattern, ensuring the result starts with a single "/" and does not end with one (unless it is
+// the root path).
+func joinPattern(prefix, pattern string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	if pattern == "" || pattern == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if pattern[0] != '/' {
+		pattern = "/" + pattern
+	}
+	return prefix + pattern
+}
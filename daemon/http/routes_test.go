@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type routesTestReq struct {
+	ID string `http:"path,id"`
+}
+
+type routesTestRes struct {
+	ID string `json:"id"`
+}
+
+func newRoutesTestService(t *testing.T) *msvc.MicroService {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("GetUser", func(ctx context.Context, req *routesTestReq) (*routesTestRes, error) {
+		return &routesTestRes{ID: req.ID}, nil
+	})
+	ms.AddMethod("Ping", func(ctx context.Context, req *struct{}) (*routesTestRes, error) {
+		return &routesTestRes{}, nil
+	})
+	return ms
+}
+
+// Returns HTTP middleware that appends the given name to the "x-trace" response header.
+func tracingMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("x-trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func routeStrings(routes []*resolvedRoute) []string {
+	result := make([]string, 0, len(routes))
+	for _, route := range routes {
+		result = append(result, route.String())
+	}
+	return result
+}
+
+func TestRoutes(t *testing.T) {
+	t.Run("groups_and_ordering", func(t *testing.T) {
+		ms := newRoutesTestService(t)
+		handler, ping := NewHandler(ms.GetMethodAdapter("GetUser")), NewHandler(ms.GetMethodAdapter("Ping"))
+		routes := NewRoutes()
+		routes.Get("/", ping)
+		v1 := routes.Group("v1")
+		v1.Method(http.MethodGet, "/users/{id}", "GetUser")
+		v1.Group("/admin/").Delete("/users/{id}", handler).Handle("", "/debug", ping)
+		routes.Post("/users/{id}", handler)
+
+		resolved, err := routes.resolve(ms)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"GET /",
+			"GET /v1/users/{id}",
+			"DELETE /v1/admin/users/{id}",
+			"* /v1/admin/debug",
+			"POST /users/{id}",
+		}, routeStrings(resolved))
+	})
+	t.Run("middleware", func(t *testing.T) {
+		ms := newRoutesTestService(t)
+		routes := NewRoutes(WithHTTPMiddleware(tracingMiddleware("root"))).AllowUnboundMethods("Ping")
+		routes.Group("/v1", WithHTTPMiddleware(tracingMiddleware("group"))).
+			Method(http.MethodGet, "/users/{id}", "GetUser", WithHTTPMiddleware(tracingMiddleware("route"))).
+			Method(http.MethodDelete, "/users/{id}", "GetUser")
+		router, err := createRouter(ms, &Config{}, routes)
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodGet, url+"/v1/users/1", nil)
+		request.Header.Set("accept", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"id":"1"}`, response.Body.String())
+		require.Equal(t, []string{"root", "group", "route"}, response.Header()["X-Trace"])
+
+		request = httptest.NewRequest(http.MethodDelete, url+"/v1/users/1", nil)
+		request.Header.Set("accept", "application/json")
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, []string{"root", "group"}, response.Header()["X-Trace"])
+	})
+	t.Run("method_middleware", func(t *testing.T) {
+		ms := newRoutesTestService(t)
+		ms.AddMiddleware(func(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				response, err := method(ctx, request)
+				if res, ok := response.(*routesTestRes); ok {
+					res.ID = descriptor.Name + ":" + res.ID
+				}
+				return response, err
+			}
+		}, msvc.ForMethods("GetUser"))
+		routes := NewRoutes().AllowUnboundMethods("Ping")
+		routes.Method(http.MethodGet, "/users/{id}", "GetUser")
+		router, err := createRouter(ms, &Config{}, routes)
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodGet, url+"/users/1", nil)
+		request.Header.Set("accept", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"id":"GetUser:1"}`, response.Body.String())
+	})
+	t.Run("all_errors", func(t *testing.T) {
+		type BadReq struct{ P string }
+		ms := newRoutesTestService(t)
+		ms.AddMethod("Bad", func(ctx context.Context, req *BadReq) (*routesTestRes, error) { return nil, nil })
+		routes := NewRoutes()
+		routes.Method(http.MethodGet, "/missing", "Missing")
+		routes.Method("FOO", "/foo", "GetUser")
+		routes.Handle(http.MethodGet, "/nil", nil)
+		routes.Method(http.MethodGet, "/bad", "Bad")
+		routes.Method(http.MethodGet, "/users/{id}", "GetUser")
+		routes.Group("users").Method(http.MethodGet, "{id}", "GetUser")
+		routes.AllowUnboundMethods("Ping")
+
+		_, err := routes.resolve(ms)
+		require.EqualError(t, err, "invalid HTTP routes:\n"+
+			"route 'GET /missing' refers to unknown method 'Missing'\n"+
+			"unknown HTTP method in route 'FOO /foo'\n"+
+			"nil handler for route 'GET /nil'\n"+
+			"bad handler for method 'Bad': failed creating request decoder for 'http.BadReq': missing 'http' tag for field 'P'\n"+
+			"duplicate route 'GET /users/{id}'\n"+
+			"method 'Bad' is not bound to any route")
+	})
+}
+
+func TestRoutesFromMap(t *testing.T) {
+	ms := newRoutesTestService(t)
+	handler, ping := NewHandler(ms.GetMethodAdapter("GetUser")), NewHandler(ms.GetMethodAdapter("Ping"))
+	t.Run("compatible", func(t *testing.T) {
+		resolved, err := RoutesFromMap(map[string]interface{}{
+			"/v1": map[string]interface{}{
+				"users/{id}": map[string]interface{}{
+					"get":    handler,
+					"DELETE": handler,
+				},
+				"debug": ping,
+			},
+			"v2": NewConventionalRoutes(ms),
+		}).resolve(ms)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"* /v1/debug",
+			"DELETE /v1/users/{id}",
+			"GET /v1/users/{id}",
+			"GET /v2/users/{id}",
+			"POST /v2/ping",
+		}, routeStrings(resolved))
+	})
+	t.Run("bad_entries", func(t *testing.T) {
+		_, err := RoutesFromMap(map[string]interface{}{
+			"a": "b",
+			"GET": map[string]interface{}{
+				"POST": 1,
+			},
+		}).resolve(ms)
+		require.EqualError(t, err, "invalid HTTP routes:\nbad routes map in 'POST: 1'\nbad routes map in 'a: b'")
+	})
+	t.Run("unbound_methods", func(t *testing.T) {
+		resolved, err := RoutesFromMap(map[string]interface{}{"/debug": ping}).resolve(ms)
+		require.NoError(t, err)
+		require.Equal(t, []string{"* /debug"}, routeStrings(resolved))
+		require.NotPanics(t, func() {
+			NewHTTPServer(ms, &Config{}, map[string]interface{}{"/debug": ping})
+		})
+	})
+	t.Run("server_panics", func(t *testing.T) {
+		require.Panics(t, func() {
+			NewHTTPServer(ms, &Config{}, map[string]interface{}{"a": "b"})
+		})
+	})
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema (draft 2020-12), as used by OpenAPI 3.1 documents.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generates JSON schemas for Go types, as they are encoded by "encoding/json". Named struct types are registered as
+// definitions, and referenced using the given reference prefix (eg. "#/$defs/").
+type schemaGenerator struct {
+	refPrefix   string
+	definitions map[string]*Schema
+	names       map[reflect.Type]string
+
+	// Whether schemas describe request bodies, whose fields are required only if validated as such (since missing
+	// fields are accepted by the decoder), rather than encoded values, whose fields are required unless omitted if empty
+	requests bool
+}
+
+func newSchemaGenerator(refPrefix string) *schemaGenerator {
+	return &schemaGenerator{
+		refPrefix:   refPrefix,
+		definitions: make(map[string]*Schema),
+		names:       make(map[reflect.Type]string),
+	}
+}
+
+// Returns the schema of the given type, or nil if the type cannot be encoded as JSON (eg. functions & channels).
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if schema := g.schemaOf(t.Elem()); schema != nil {
+			return nullable(schema)
+		}
+		return nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		minimum := float64(0)
+		return &Schema{Type: "integer", Minimum: &minimum}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		if items := g.schemaOf(t.Elem()); items != nil {
+			return &Schema{Type: "array", Items: items}
+		}
+		return nil
+	case reflect.Map:
+		if values := g.schemaOf(t.Elem()); values != nil {
+			return &Schema{Type: "object", AdditionalProperties: values}
+		}
+		return nil
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: g.refPrefix + g.define(t)}
+	default:
+		return nil
+	}
+}
+
+// Registers the given named struct type as a definition (unless already registered), returning its definition name.
+func (g *schemaGenerator) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	// Disambiguate types with the same name from different packages
+	name := t.Name()
+	if _, taken := g.definitions[name]; taken {
+		name = strings.Replace(t.String(), ".", "_", -1)
+	}
+
+	// Register before generating the schema, so that recursive types refer to it
+	g.names[t] = name
+	g.definitions[name] = &Schema{}
+	*g.definitions[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(schema, t, make(map[reflect.Type]bool))
+	return schema
+}
+
+// Adds the properties of the given struct type to the given schema, following the "encoding/json" rules: fields of
+// embedded structs are promoted, unless shadowed by fields of the embedding struct. Structs already visited (ie. ones
+// embedding themselves, directly or not) are skipped, as their fields were already added.
+func (g *schemaGenerator) addFields(schema *Schema, t reflect.Type, visited map[reflect.Type]bool) {
+	visited[t] = true
+	embedded := make([]reflect.Type, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options := parseJSONTag(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, fieldType)
+			continue
+		} else if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if _, exists := schema.Properties[name]; exists {
+			continue
+		}
+
+		var property *Schema
+		if options["string"] {
+			property = &Schema{Type: "string"}
+		} else if property = g.schemaOf(field.Type); property == nil {
+			continue
+		}
+		schema.Properties[name] = property
+		if required := applyValidationRules(property, field); required || (!g.requests && !options["omitempty"]) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	for _, embeddedType := range embedded {
+		if !visited[embeddedType] {
+			g.addFields(schema, embeddedType, visited)
+		}
+	}
+}
+
+// Applies the rules of the given field's "validate" tag (as used by "github.com/go-playground/validator") to its schema,
+// returning whether the field is required. Rules without a JSON Schema equivalent are ignored, as are rules following
+// "dive" (which apply to collection items).
+func applyValidationRules(schema *Schema, field reflect.StructField) bool {
+	tag, ok := field.Tag.Lookup("validate")
+	if !ok {
+		return false
+	}
+
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		tokens := strings.SplitN(rule, "=", 2)
+		name, param := tokens[0], ""
+		if len(tokens) == 2 {
+			param = tokens[1]
+		}
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		case "datetime":
+			schema.Format = "date-time"
+		case "ipv4", "ipv6", "hostname":
+			schema.Format = name
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if number, err := strconv.ParseFloat(value, 64); err == nil && fieldType.Kind() != reflect.String {
+					schema.Enum = append(schema.Enum, number)
+				} else {
+					schema.Enum = append(schema.Enum, value)
+				}
+			}
+		case "len", "min", "max", "gt", "gte", "lt", "lte":
+			applyBoundRule(schema, fieldType, name, param)
+		}
+	}
+	return required
+}
+
+// Applies a bound rule (eg. "min=3") to the given schema: for strings & collections, as a length bound; for numbers, as
+// a value bound.
+func applyBoundRule(schema *Schema, fieldType reflect.Type, rule, param string) {
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array:
+		length, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		minimum, maximum := &schema.MinLength, &schema.MaxLength
+		if fieldType.Kind() != reflect.String {
+			minimum, maximum = &schema.MinItems, &schema.MaxItems
+		}
+		switch rule {
+		case "len":
+			*minimum, *maximum = &length, &length
+		case "min", "gte":
+			*minimum = &length
+		case "gt":
+			length++
+			*minimum = &length
+		case "max", "lte":
+			*maximum = &length
+		case "lt":
+			length--
+			*maximum = &length
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		switch rule {
+		case "len":
+			schema.Minimum, schema.Maximum = &value, &value
+		case "min", "gte":
+			schema.Minimum = &value
+		case "gt":
+			schema.ExclusiveMinimum = &value
+		case "max", "lte":
+			schema.Maximum = &value
+		case "lt":
+			schema.ExclusiveMaximum = &value
+		}
+	}
+}
+
+// Returns the name & options of the given field's "json" tag; the name is "-" if the field is not encoded.
+func parseJSONTag(field reflect.StructField) (string, map[string]bool) {
+	options := make(map[string]bool)
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return "", options
+	} else if tag == "-" {
+		return "-", options
+	}
+	tokens := strings.Split(tag, ",")
+	for _, option := range tokens[1:] {
+		options[option] = true
+	}
+	return tokens[0], options
+}
+
+// Returns a schema that also allows null values.
+func nullable(schema *Schema) *Schema {
+	switch t := schema.Type.(type) {
+	case string:
+		nullableSchema := *schema
+		nullableSchema.Type = []string{t, "null"}
+		return &nullableSchema
+	case []string:
+		return schema
+	case nil:
+		if schema.Ref == "" && schema.AnyOf == nil {
+			return schema
+		}
+	}
+	return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+}
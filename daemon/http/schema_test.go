@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaTestBase struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type schemaTestNode struct {
+	schemaTestBase
+	Name     string            `json:"name,omitempty"`
+	Parent   *schemaTestNode   `json:"parent"`
+	Children []schemaTestNode  `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Count    *int              `json:"count"`
+	Size     uint8             `json:"size,string"`
+	Data     []byte            `json:"data,omitempty"`
+	Any      interface{}       `json:"any,omitempty"`
+	Skipped  string            `json:"-"`
+	Callback func()            `json:"callback,omitempty"`
+	internal string
+	Plain    bool
+}
+
+type schemaTestSelfEmbedding struct {
+	*schemaTestSelfEmbedding
+	Name string `json:"name"`
+}
+
+func TestSchemaGenerator(t *testing.T) {
+	generator := newSchemaGenerator("#/$defs/")
+	schema := generator.schemaOf(reflect.TypeOf(schemaTestNode{}))
+	require.Equal(t, &Schema{Ref: "#/$defs/schemaTestNode"}, schema)
+
+	actual, err := json.Marshal(generator.definitions)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"schemaTestNode": {
+			"type": "object",
+			"properties": {
+				"id": {"type": "string"},
+				"created": {"type": "string", "format": "date-time"},
+				"name": {"type": "string"},
+				"parent": {"anyOf": [{"$ref": "#/$defs/schemaTestNode"}, {"type": "null"}]},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/schemaTestNode"}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"count": {"type": ["integer", "null"], "format": "int64"},
+				"size": {"type": "string"},
+				"data": {"type": "string", "contentEncoding": "base64"},
+				"any": {},
+				"Plain": {"type": "boolean"}
+			},
+			"required": ["parent", "count", "size", "Plain", "id", "created"]
+		}
+	}`, string(actual))
+}
+
+func TestSchemaValidationRules(t *testing.T) {
+	type Req struct {
+		Email   string   `json:"email,omitempty" validate:"required,email"`
+		Name    *string  `json:"name" validate:"min=2,max=10"`
+		Code    string   `json:"code" validate:"len=3"`
+		Tags    []string `json:"tags" validate:"gt=0,dive,min=5"`
+		Age     int      `json:"age" validate:"gte=18,lt=120"`
+		Score   float64  `json:"score" validate:"gt=0,lte=1"`
+		Color   string   `json:"color" validate:"oneof=red green"`
+		Level   int      `json:"level" validate:"oneof=1 2 3"`
+		Website string   `json:"website,omitempty" validate:"omitempty,url"`
+	}
+
+	generator := newSchemaGenerator("#/$defs/")
+	generator.schemaOf(reflect.TypeOf(Req{}))
+	actual, err := json.Marshal(generator.definitions["Req"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"email": {"type": "string", "format": "email"},
+			"name": {"type": ["string", "null"], "minLength": 2, "maxLength": 10},
+			"code": {"type": "string", "minLength": 3, "maxLength": 3},
+			"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1},
+			"age": {"type": "integer", "format": "int64", "minimum": 18, "exclusiveMaximum": 120},
+			"score": {"type": "number", "format": "double", "exclusiveMinimum": 0, "maximum": 1},
+			"color": {"type": "string", "enum": ["red", "green"]},
+			"level": {"type": "integer", "format": "int64", "enum": [1, 2, 3]},
+			"website": {"type": "string", "format": "uri"}
+		},
+		"required": ["email", "name", "code", "tags", "age", "score", "color", "level"]
+	}`, string(actual))
+}
+
+func TestSchemaGeneratorSelfEmbedding(t *testing.T) {
+	generator := newSchemaGenerator("#/$defs/")
+	generator.schemaOf(reflect.TypeOf(schemaTestSelfEmbedding{}))
+	actual, err := json.Marshal(generator.definitions["schemaTestSelfEmbedding"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}`, string(actual))
+}
@@ -1,9 +1,14 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
 type Config struct {
@@ -12,18 +17,170 @@ type Config struct {
 		Host string
 		Port uint16
 	}
+	OpenAPI struct {
+		// Path to serve the OpenAPI document at; defaults to "/openapi.json", and "-" disables it.
+		Path string
+
+		// Version of the API described by the OpenAPI document.
+		Version string
+	}
+	Schemas struct {
+		// Path under which to serve the JSON schemas of methods; defaults to "/schemas", and "-" disables them.
+		Path string
+	}
+	SSE struct {
+		// Reconnection delay hinted to clients of server-sent event streams; defaults to 3 seconds.
+		Retry time.Duration
+
+		// Interval of keep-alive comments sent on idle server-sent event streams; defaults to 15 seconds.
+		KeepAlive time.Duration
+	}
+}
+
+type serverRun struct {
+	server       *http.Server
+	shutdown     sync.Once
+	shuttingDown chan struct{}
+	drained      chan struct{}
+	err          error
+}
+
+type shuttingDownContextKey struct{}
+
+// Returns a channel that is closed once the server serving the request of the given context starts shutting down (or a
+// nil channel, if the request is not served by a server daemon). Long-lived responses (eg. event streams) must end when
+// it is closed, since shutting down waits for all responses to complete.
+func shuttingDown(ctx context.Context) <-chan struct{} {
+	if ch, ok := ctx.Value(shuttingDownContextKey{}).(chan struct{}); ok {
+		return ch
+	}
+	return nil
+}
+
+type serverDaemon struct {
+	name    string
+	addr    string
+	handler http.Handler
+	mutex   sync.Mutex
+	status  msvc.DaemonStatus
+	run     *serverRun
 }
 
+// Creates the HTTP server daemon from a routes map (see RoutesFromMap), panicking if the routes are invalid.
 func NewHTTPServer(ms *msvc.MicroService, config *Config, handlers map[string]interface{}) msvc.Daemon {
-	return func() error {
-		server := &http.Server{
-			Addr:    fmt.Sprintf(":%d", config.Port),
-			Handler: createRouter(ms, config.CORS.Host, config.CORS.Port, handlers),
+	daemon, err := NewHTTPServerWithRoutes(ms, config, RoutesFromMap(handlers))
+	if err != nil {
+		panic(err)
+	}
+	return daemon
+}
+
+// Creates the HTTP server daemon serving the given routes, or returns all configuration errors found in them.
+func NewHTTPServerWithRoutes(ms *msvc.MicroService, config *Config, routes *Routes) (msvc.Daemon, error) {
+	router, err := createRouter(ms, config, routes)
+	if err != nil {
+		return nil, err
+	}
+	return NewServer("http", fmt.Sprintf(":%d", config.Port), router), nil
+}
+
+// Creates a daemon serving the given handler on the given address. Stopping the daemon stops accepting new connections,
+// and waits for in-flight requests to complete.
+func NewServer(name string, addr string, handler http.Handler) msvc.Daemon {
+	return &serverDaemon{name: name, addr: addr, handler: handler}
+}
+
+func (d *serverDaemon) Name() string {
+	return d.name
+}
+
+func (d *serverDaemon) Start(ctx context.Context) error {
+	d.mutex.Lock()
+	if d.run != nil {
+		d.mutex.Unlock()
+		return errors.Errorf("daemon '%s' already started", d.name)
+	}
+	d.status = msvc.DaemonStarting
+	d.mutex.Unlock()
+
+	listener, err := net.Listen("tcp", d.addr)
+	if err != nil {
+		d.setStatus(msvc.DaemonFailed)
+		return errors.Wrapf(err, "failed listening on '%s'", d.addr)
+	}
+
+	run := &serverRun{shuttingDown: make(chan struct{}), drained: make(chan struct{})}
+	run.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shuttingDownContextKey{}, run.shuttingDown)))
+	})}
+	run.server.RegisterOnShutdown(func() { close(run.shuttingDown) })
+	d.mutex.Lock()
+	d.run, d.status = run, msvc.DaemonReady
+	d.mutex.Unlock()
+
+	// Stop the server when the context is cancelled
+	served := make(chan struct{})
+	defer close(served)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownTimeout := msvc.DefaultShutdownTimeout
+			if ms := msvc.GetFromContext(ctx); ms != nil {
+				shutdownTimeout = ms.ShutdownTimeout()
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			_ = d.Stop(shutdownCtx)
+		case <-served:
 		}
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			return err
-		} else {
-			return nil
+	}()
+
+	// Serve until the server is shut down; when that happens, wait for the shutdown to finish draining
+	err = run.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		<-run.drained
+		err = run.err
+		if err != nil {
+			err = errors.Wrap(err, "failed draining HTTP server")
 		}
 	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.run = nil
+	if err != nil {
+		d.status = msvc.DaemonFailed
+		return err
+	}
+	d.status = msvc.DaemonStopped
+	return nil
+}
+
+func (d *serverDaemon) Stop(ctx context.Context) error {
+	d.mutex.Lock()
+	run := d.run
+	if run == nil {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.status = msvc.DaemonStopping
+	d.mutex.Unlock()
+
+	run.shutdown.Do(func() {
+		run.err = run.server.Shutdown(ctx)
+		close(run.drained)
+	})
+	return run.err
+}
+
+func (d *serverDaemon) Status() msvc.DaemonStatus {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status
+}
+
+func (d *serverDaemon) setStatus(status msvc.DaemonStatus) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.status = status
 }
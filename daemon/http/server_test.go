@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) uint16 {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestHTTPServer(t *testing.T) {
+	t.Run("drains_in_flight_requests_on_shutdown", func(t *testing.T) {
+		ms, err := msvc.New("test", &struct{}{})
+		require.NoError(t, err)
+
+		type Req struct{}
+		type Res struct{ P string }
+		started := make(chan struct{})
+		release := make(chan struct{})
+		adapter := ms.AddMethod("Slow", func(ctx context.Context, req *Req) (*Res, error) {
+			close(started)
+			<-release
+			return &Res{P: "v"}, nil
+		})
+
+		config := &Config{Port: freePort(t)}
+		daemon := NewHTTPServer(ms, config, map[string]interface{}{"slow": map[string]interface{}{"GET": NewHandler(adapter)}})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		daemonResult := make(chan error, 1)
+		go func() { daemonResult <- daemon.Start(ctx) }()
+
+		// Send a request that blocks inside the method until released
+		type clientResult struct {
+			status int
+			body   string
+			err    error
+		}
+		responseResult := make(chan clientResult, 1)
+		go func() {
+			var res *http.Response
+			var err error
+			for i := 0; i < 50; i++ {
+				request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%d/slow", config.Port), nil)
+				request.Header.Set("accept", "application/json")
+				if res, err = http.DefaultClient.Do(request); err == nil {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			if err != nil {
+				responseResult <- clientResult{err: err}
+				return
+			}
+			defer res.Body.Close()
+			body, err := ioutil.ReadAll(res.Body)
+			responseResult <- clientResult{status: res.StatusCode, body: string(body), err: err}
+		}()
+		<-started
+
+		// Initiate shutdown; daemon must wait for the in-flight request
+		cancel()
+		select {
+		case err := <-daemonResult:
+			t.Fatalf("daemon exited before in-flight request completed: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		result := <-responseResult
+		require.NoError(t, result.err)
+		require.Equal(t, http.StatusOK, result.status)
+		require.Equal(t, "{\n  \"P\": \"v\"\n}\n", result.body)
+		require.NoError(t, <-daemonResult)
+	})
+	t.Run("stop", func(t *testing.T) {
+		daemon := NewServer("test", fmt.Sprintf(":%d", freePort(t)), http.NotFoundHandler())
+		require.Equal(t, "test", daemon.Name())
+		require.Equal(t, msvc.DaemonStopped, daemon.Status())
+
+		daemonResult := make(chan error, 1)
+		go func() { daemonResult <- daemon.Start(context.Background()) }()
+		for daemon.Status() != msvc.DaemonReady {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		require.NoError(t, daemon.Stop(context.Background()))
+		require.NoError(t, <-daemonResult)
+		require.Equal(t, msvc.DaemonStopped, daemon.Status())
+	})
+	t.Run("listen_failure", func(t *testing.T) {
+		listener, err := net.Listen("tcp", ":0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		daemon := NewServer("test", listener.Addr().String(), http.NotFoundHandler())
+		require.Error(t, daemon.Start(context.Background()))
+		require.Equal(t, msvc.DaemonFailed, daemon.Status())
+	})
+}
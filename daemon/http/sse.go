@@ -0,0 +1,262 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Media type of server-sent event streams.
+	EventStreamMediaType = "text/event-stream"
+
+	// Reconnection delay hinted to clients of server-sent event streams, unless configured otherwise.
+	DefaultEventStreamRetry = 3 * time.Second
+
+	// Interval of keep-alive comments sent on idle server-sent event streams, unless configured otherwise.
+	DefaultEventStreamKeepAlive = 15 * time.Second
+)
+
+type eventStreamContextKey struct{}
+
+type lastEventIDContextKey struct{}
+
+// Settings of server-sent event streams, provided to handlers by the router.
+type eventStreamSettings struct {
+	retry     time.Duration
+	keepAlive time.Duration
+}
+
+// Optional interface of streamed responses, providing the ID of their server-sent events. Events of responses not
+// implementing it are numbered sequentially, continuing from the "Last-Event-ID" of resumed streams (if numeric).
+type EventIDer interface {
+	EventID() string
+}
+
+// Optional interface of streamed responses, providing the type of their server-sent events ("message" by default).
+type EventNamer interface {
+	EventName() string
+}
+
+// Returns the ID of the last server-sent event received by the client, when it resumes a stream (via the
+// "Last-Event-ID" header), or an empty string. Streaming methods can use it to resume streams where they stopped.
+func LastEventID(ctx context.Context) string {
+	if id, ok := ctx.Value(lastEventIDContextKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// Returns a copy of the given context carrying the given "Last-Event-ID".
+func withLastEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, lastEventIDContextKey{}, id)
+}
+
+// Returns the server-sent event stream settings in the given context, or the defaults.
+func eventStreamSettingsFrom(ctx context.Context) eventStreamSettings {
+	if settings, ok := ctx.Value(eventStreamContextKey{}).(eventStreamSettings); ok {
+		return settings
+	}
+	return eventStreamSettings{DefaultEventStreamRetry, DefaultEventStreamKeepAlive}
+}
+
+// Serves the given stream of a streaming method. Clients get server-sent events by default, or a JSON array or
+// newline-delimited JSON if they accept those specifically.
+func serveStream(stream msvc.Stream, r *http.Request, w http.ResponseWriter) {
+	mediaType, ok := negotiateMediaTypeAmong(r, []string{EventStreamMediaType, DefaultMediaType, NDJSONMediaType})
+	if ok && mediaType != EventStreamMediaType {
+		encoder := &responseEncoder{}
+		encoder.streamServiceResponse(&splitResponse{status: http.StatusOK, header: make(http.Header)}, stream, r, w)
+		return
+	}
+
+	defer stream.Close()
+	if !ok {
+		if ms := msvc.GetFromContext(r.Context()); ms != nil {
+			ms.Log("err", errors.Errorf("none of '%s' is supported for streams", r.Header.Get("accept")))
+		}
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+	serveEventStream(stream, r, w)
+}
+
+// A response (or error) received from a stream.
+type streamResult struct {
+	response interface{}
+	ok       bool
+	err      error
+}
+
+// Serves the given stream as server-sent events: each response is sent as a JSON-encoded event, failures as an "error"
+// event (see eventStreamError), and the end of the stream as an "end" event (so clients can stop reconnecting). Methods
+// failing before their first response are instead answered with the status code of their error. The stream is received
+// in the background, and stops when the client disconnects; keep-alive comments are sent while it is idle. When the
+// server shuts down, the stream is stopped with a "shutdown" event, so clients reconnect (and resume) elsewhere.
+func serveEventStream(stream msvc.Stream, r *http.Request, w http.ResponseWriter) {
+	ms := msvc.GetFromContext(r.Context())
+	settings := eventStreamSettingsFrom(r.Context())
+	encoder := encoderFor(DefaultMediaType)
+	if encoder == nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	// Events are numbered sequentially, continuing from the last event ID if numeric
+	nextID := uint64(1)
+	if lastID, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		nextID = lastID + 1
+	}
+
+	// Receive responses in the background, until the stream ends or the client disconnects
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	results := make(chan streamResult)
+	go func() {
+		defer close(results)
+		for {
+			response, ok, err := stream.Next(ctx)
+			select {
+			case results <- streamResult{response, ok, err}:
+			case <-ctx.Done():
+				return
+			}
+			if !ok {
+				return
+			}
+		}
+	}()
+
+	// Wait for the first result before writing the headers (up to the keep-alive interval, so idle streams are still
+	// kept alive), so that early failures are reported with a proper status code
+	shutdown := shuttingDown(ctx)
+	var first *streamResult
+	firstTimeout := time.NewTimer(settings.keepAlive)
+	defer firstTimeout.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-shutdown:
+	case <-firstTimeout.C:
+	case result := <-results:
+		if result.err != nil {
+			(&responseEncoder{}).MarshallServiceResponseAndError(nil, result.err, r, w)
+			return
+		}
+		first = &result
+	}
+
+	w.Header().Set("Content-Type", contentTypeOf(EventStreamMediaType))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", settings.retry/time.Millisecond); err != nil {
+		return
+	}
+	flush()
+
+	keepAlive := time.NewTicker(settings.keepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		result, received := streamResult{}, false
+		if first != nil {
+			result, received, first = *first, true, nil
+		} else {
+			select {
+			case <-ctx.Done():
+				return
+			case <-shutdown:
+				_ = writeEvent(w, encoder, "", "shutdown", nil)
+				flush()
+				return
+			case <-keepAlive.C:
+				_, err = io.WriteString(w, ": keep-alive\n\n")
+			case result = <-results:
+				received = true
+			}
+		}
+		if received {
+			switch {
+			case result.err != nil:
+				if ms != nil {
+					ms.Log("err", result.err, "msg", "streaming method failed")
+				}
+				_ = writeEvent(w, encoder, "", "error", eventStreamError(result.err))
+				flush()
+				return
+			case !result.ok:
+				_ = writeEvent(w, encoder, "", "end", nil)
+				flush()
+				return
+			}
+
+			id := strconv.FormatUint(nextID, 10)
+			nextID++
+			if ider, ok := result.response.(EventIDer); ok {
+				id = ider.EventID()
+			}
+			name := ""
+			if namer, ok := result.response.(EventNamer); ok {
+				name = namer.EventName()
+			}
+			err = writeEvent(w, encoder, id, name, result.response)
+		}
+		if err != nil {
+			if ms != nil {
+				ms.Log("err", err, "msg", "failed writing server-sent event")
+			}
+			return
+		}
+		flush()
+	}
+}
+
+// Returns the data of the "error" event of a stream failing with the given error: its status code & cause, for ErrHttp
+// errors, or a generic internal error otherwise (so internal failure details are not disclosed to clients).
+func eventStreamError(err error) map[string]interface{} {
+	code, message := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	if httpErr, ok := err.(ErrHttp); ok {
+		code, message = httpErr.Code(), http.StatusText(httpErr.Code())
+		if httpErr.Cause() != nil {
+			message = httpErr.Cause().Error()
+		}
+	}
+	return map[string]interface{}{"code": code, "error": message}
+}
+
+// Writes a server-sent event with the given ID & name (omitted if empty), and the given data encoded by the given
+// encoder (as one "data" line per encoded line).
+func writeEvent(w io.Writer, encoder BodyEncoder, id, name string, data interface{}) error {
+	buffer := new(bytes.Buffer)
+	if err := encoder.Encode(buffer, data, false); err != nil {
+		return errors.Wrap(err, "failed encoding event data")
+	}
+
+	var sb strings.Builder
+	if id != "" {
+		sb.WriteString("id: " + strings.NewReplacer("\n", "", "\r", "", "\x00", "").Replace(id) + "\n")
+	}
+	if name != "" {
+		sb.WriteString("event: " + strings.NewReplacer("\n", "", "\r", "").Replace(name) + "\n")
+	}
+	for _, line := range strings.Split(strings.TrimRight(buffer.String(), "\r\n"), "\n") {
+		sb.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	sb.WriteString("\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type sseTestRequest struct {
+	Count int `http:"query,count"`
+}
+
+type sseTestEvent struct {
+	N int `json:"n"`
+}
+
+type sseTestNamedEvent struct {
+	N int `json:"n"`
+}
+
+func (e *sseTestNamedEvent) EventID() string   { return "e" + strconv.Itoa(e.N) }
+func (e *sseTestNamedEvent) EventName() string { return "tick" }
+
+// Returns a handler of a streaming method sending the requested number of events (continuing after the last event ID),
+// and then failing with the given error (if any).
+func newSSETestHandler(err error) *handler {
+	return NewHandler(msvc.NewAdapter(func(ctx context.Context, req *sseTestRequest, send func(*sseTestEvent) error) error {
+		first, _ := strconv.Atoi(LastEventID(ctx))
+		for i := first + 1; i <= first+req.Count; i++ {
+			if err := send(&sseTestEvent{i}); err != nil {
+				return err
+			}
+		}
+		return err
+	}))
+}
+
+func TestServerSentEvents(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url+"?count=2", nil)
+		response := httptest.NewRecorder()
+		newSSETestHandler(nil).Handle(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "text/event-stream; charset=utf-8", response.Header().Get("content-type"))
+		require.Equal(t, "no-cache", response.Header().Get("cache-control"))
+		require.Equal(t, "retry: 3000\n\n"+
+			"id: 1\ndata: {\"n\":1}\n\n"+
+			"id: 2\ndata: {\"n\":2}\n\n"+
+			"event: end\ndata: null\n\n", response.Body.String())
+		require.True(t, response.Flushed)
+	})
+	t.Run("resumed", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url+"?count=1", nil)
+		request.Header.Set("accept", "text/event-stream")
+		request.Header.Set("last-event-id", "7")
+		response := httptest.NewRecorder()
+		newSSETestHandler(nil).Handle(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "retry: 3000\n\nid: 8\ndata: {\"n\":8}\n\nevent: end\ndata: null\n\n", response.Body.String())
+	})
+	t.Run("named_events", func(t *testing.T) {
+		handler := NewHandler(msvc.NewAdapter(func(ctx context.Context, req *sseTestRequest, send func(*sseTestNamedEvent) error) error {
+			return send(&sseTestNamedEvent{1})
+		}))
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		response := httptest.NewRecorder()
+		handler.Handle(response, request)
+		require.Equal(t, "retry: 3000\n\nid: e1\nevent: tick\ndata: {\"n\":1}\n\nevent: end\ndata: null\n\n", response.Body.String())
+	})
+	t.Run("error", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url+"?count=1", nil)
+		response := httptest.NewRecorder()
+		newSSETestHandler(NewHttpError(http.StatusConflict, errors.New("gone"))).Handle(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "retry: 3000\n\nid: 1\ndata: {\"n\":1}\n\nevent: error\ndata: {\"code\":409,\"error\":\"gone\"}\n\n", response.Body.String())
+	})
+	t.Run("error_before_first_event", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		response := httptest.NewRecorder()
+		newSSETestHandler(NewHttpError(http.StatusNotFound, errors.New("no such job"))).Handle(response, request)
+		require.Equal(t, http.StatusNotFound, response.Code)
+		require.NotContains(t, response.Body.String(), "event:")
+	})
+	t.Run("internal_error", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url+"?count=1", nil)
+		response := httptest.NewRecorder()
+		newSSETestHandler(errors.New("db password 'secret' rejected")).Handle(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.NotContains(t, response.Body.String(), "db password")
+		require.Equal(t, "retry: 3000\n\nid: 1\ndata: {\"n\":1}\n\nevent: error\ndata: {\"code\":500,\"error\":\"Internal Server Error\"}\n\n", response.Body.String())
+	})
+	t.Run("settings", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		ctx := context.WithValue(request.Context(), eventStreamContextKey{}, eventStreamSettings{time.Second, time.Millisecond})
+		response := httptest.NewRecorder()
+		handler := NewHandler(msvc.NewAdapter(func(ctx context.Context, req *sseTestRequest, send func(*sseTestEvent) error) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}))
+		handler.Handle(response, request.WithContext(ctx))
+		require.Regexp(t, "^retry: 1000\n\n(: keep-alive\n\n)+event: end\ndata: null\n\n$", response.Body.String())
+	})
+	t.Run("json", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url+"?count=2", nil)
+		request.Header.Set("accept", "application/json")
+		response := httptest.NewRecorder()
+		newSSETestHandler(nil).Handle(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "application/json; charset=utf-8", response.Header().Get("content-type"))
+		require.JSONEq(t, `[{"n": 1}, {"n": 2}]`, response.Body.String())
+	})
+	t.Run("not_acceptable", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		request.Header.Set("accept", "application/xml")
+		response := httptest.NewRecorder()
+		newSSETestHandler(nil).Handle(response, request)
+		require.Equal(t, http.StatusNotAcceptable, response.Code)
+	})
+	t.Run("server_shutdown", func(t *testing.T) {
+		ms, err := msvc.New("test", &struct{}{})
+		require.NoError(t, err)
+		cancelled := make(chan struct{})
+		ms.AddMethod("Watch", func(ctx context.Context, req *sseTestRequest, send func(*sseTestEvent) error) error {
+			if err := send(&sseTestEvent{1}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		})
+		config := &Config{Port: freePort(t)}
+		daemon, err := NewHTTPServerWithRoutes(ms, config, NewRoutes().Method(http.MethodGet, "/watch", "Watch"))
+		require.NoError(t, err)
+		daemonResult := make(chan error, 1)
+		go func() { daemonResult <- daemon.Start(context.Background()) }()
+		for daemon.Status() != msvc.DaemonReady {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		response, err := http.Get(fmt.Sprintf("http://localhost:%d/watch", config.Port))
+		require.NoError(t, err)
+		defer response.Body.Close()
+		reader := bufio.NewReader(response.Body)
+		for line := ""; line != "data: {\"n\":1}\n"; {
+			line, err = reader.ReadString('\n')
+			require.NoError(t, err)
+		}
+
+		// Stopping the daemon ends the open stream (rather than waiting for the client to disconnect)
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		require.NoError(t, daemon.Stop(stopCtx))
+		require.NoError(t, <-daemonResult)
+		rest, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "\nevent: shutdown\ndata: null\n\n", string(rest))
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			require.Fail(t, "streaming method was not cancelled")
+		}
+	})
+	t.Run("client_disconnected", func(t *testing.T) {
+		sendErr := make(chan error, 1)
+		handler := NewHandler(msvc.NewAdapter(func(ctx context.Context, req *sseTestRequest, send func(*sseTestEvent) error) error {
+			err := send(&sseTestEvent{1})
+			for err == nil {
+				err = send(&sseTestEvent{2})
+			}
+			sendErr <- err
+			return err
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		request := httptest.NewRequest(http.MethodGet, url, nil).WithContext(ctx)
+		response := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler.Handle(response, request)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			require.Fail(t, "stream was not stopped")
+		}
+		select {
+		case err := <-sendErr:
+			require.Equal(t, context.Canceled, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "streaming method was not cancelled")
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const (
+	// Media type of streamed responses encoded as newline-delimited JSON (one item per line).
+	NDJSONMediaType = "application/x-ndjson"
+
+	// Trailer reporting errors that occurred while streaming a response, after its headers were sent.
+	StreamErrorTrailer = "X-Stream-Error"
+)
+
+// Iterates over the items of a streamed response. Response bodies (or whole responses) implementing Iterator are
+// encoded incrementally, item by item, as a JSON array or as newline-delimited JSON (if accepted by the client), and are
+// flushed to the client after each item. If the iterator also implements io.Closer, it is closed when streaming ends.
+type Iterator interface {
+	// Returns the next item, or false if there are no more items. The given context is cancelled if the client
+	// disconnects.
+	Next(ctx context.Context) (interface{}, bool, error)
+}
+
+// Adapts a function to the Iterator interface.
+type IteratorFunc func(ctx context.Context) (interface{}, bool, error)
+
+func (f IteratorFunc) Next(ctx context.Context) (interface{}, bool, error) {
+	return f(ctx)
+}
+
+// Returns an iterator over the items received from the given channel (of any element type), until it is closed.
+// Received errors end the stream with that error. Producers should stop sending (and close the channel) when the
+// request context is done, since the iterator stops receiving once the client disconnects.
+func ChannelIterator(channel interface{}) Iterator {
+	channelValue := reflect.ValueOf(channel)
+	if channelValue.Kind() != reflect.Chan || channelValue.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(errors.Errorf("expected receivable channel; received '%T'", channel))
+	}
+	return IteratorFunc(func(ctx context.Context) (interface{}, bool, error) {
+		chosen, value, ok := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: channelValue},
+		})
+		if chosen == 0 {
+			return nil, false, ctx.Err()
+		} else if !ok {
+			return nil, false, nil
+		} else if err, isErr := value.Interface().(error); isErr {
+			return nil, false, err
+		}
+		return value.Interface(), true, nil
+	})
+}
+
+// Streams the items of the given iterator to the client, as a JSON array or as newline-delimited JSON. The first item
+// is fetched before the headers are written, so that early errors are reported with a proper status code; later errors
+// are reported via the "X-Stream-Error" trailer (and leave the JSON array unterminated).
+func (h *responseEncoder) streamServiceResponse(response *splitResponse, iterator Iterator, r *http.Request, w http.ResponseWriter) {
+	ms := msvc.GetFromContext(r.Context())
+	if closer, ok := iterator.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil && ms != nil {
+				ms.Log("err", err, "msg", "failed closing response iterator")
+			}
+		}()
+	}
+
+	mediaType, ok := negotiateMediaTypeAmong(r, []string{DefaultMediaType, NDJSONMediaType})
+	if !ok {
+		if ms != nil {
+			ms.Log("err", errors.Errorf("none of '%s' is supported for streams", r.Header.Get("accept")))
+		}
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+	encoder := encoderFor(DefaultMediaType)
+	if encoder == nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	item, hasItem, err := iterator.Next(r.Context())
+	if err != nil {
+		h.MarshallServiceResponseAndError(nil, err, r, w)
+		return
+	}
+
+	response.writeHeaders(w)
+	w.Header().Set("Content-Type", contentTypeOf(mediaType))
+	w.Header().Add("Trailer", StreamErrorTrailer)
+	w.WriteHeader(response.status)
+
+	flusher, _ := w.(http.Flusher)
+	array := mediaType == DefaultMediaType
+	write := func(s string) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+	if array {
+		err = write("[")
+	}
+	for first := true; err == nil && hasItem; first = false {
+		if array && !first {
+			err = write(",")
+		}
+		if err == nil {
+			err = encoder.Encode(w, item, false)
+		}
+		if err == nil {
+			if flusher != nil {
+				flusher.Flush()
+			}
+			item, hasItem, err = iterator.Next(r.Context())
+		}
+	}
+	if err == nil && array {
+		err = write("]\n")
+	}
+
+	if err != nil {
+		if ms != nil {
+			ms.Log("err", err, "msg", "failed streaming response")
+		}
+		if httpErr, ok := err.(ErrHttp); ok && httpErr.Cause() != nil {
+			err = httpErr.Cause()
+		}
+		w.Header().Set(StreamErrorTrailer, strings.Replace(err.Error(), "\n", " ", -1))
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type streamTestItem struct {
+	N int `json:"n"`
+}
+
+type streamTestResponse struct {
+	Count int      `http:"header,x-count"`
+	Items Iterator `http:"body"`
+}
+
+type closingIterator struct {
+	Iterator
+	closed bool
+}
+
+func (i *closingIterator) Close() error {
+	i.closed = true
+	return nil
+}
+
+// Returns an iterator over the given number of items, failing with the given error (if any) after them.
+func countingIterator(count int, err error) Iterator {
+	n := 0
+	return IteratorFunc(func(ctx context.Context) (interface{}, bool, error) {
+		if n == count {
+			return nil, false, err
+		}
+		n++
+		return &streamTestItem{n}, true, nil
+	})
+}
+
+func TestStreamServiceResponse(t *testing.T) {
+	encoder, err := newResponseEncoder(reflect.TypeOf(streamTestResponse{}))
+	require.NoError(t, err)
+	stream := func(accept string, iterator Iterator) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			request.Header.Set("accept", accept)
+		}
+		response := httptest.NewRecorder()
+		encoder.MarshallServiceResponse(&streamTestResponse{Count: 2, Items: iterator}, request, response)
+		return response
+	}
+
+	t.Run("json_array", func(t *testing.T) {
+		iterator := &closingIterator{Iterator: countingIterator(2, nil)}
+		response := stream("", iterator)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "application/json; charset=utf-8", response.Header().Get("content-type"))
+		require.Equal(t, "2", response.Header().Get("x-count"))
+		require.Equal(t, "[{\"n\":1}\n,{\"n\":2}\n]\n", response.Body.String())
+		require.JSONEq(t, `[{"n": 1}, {"n": 2}]`, response.Body.String())
+		require.Equal(t, "", response.Result().Trailer.Get(StreamErrorTrailer))
+		require.True(t, response.Flushed)
+		require.True(t, iterator.closed)
+	})
+	t.Run("empty_json_array", func(t *testing.T) {
+		response := stream("application/json", countingIterator(0, nil))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `[]`, response.Body.String())
+	})
+	t.Run("ndjson", func(t *testing.T) {
+		response := stream("application/x-ndjson, application/json;q=0.5", countingIterator(3, nil))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, NDJSONMediaType, response.Header().Get("content-type"))
+		require.Equal(t, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n", response.Body.String())
+	})
+	t.Run("not_acceptable", func(t *testing.T) {
+		iterator := &closingIterator{Iterator: countingIterator(1, nil)}
+		response := stream("application/xml", iterator)
+		require.Equal(t, http.StatusNotAcceptable, response.Code)
+		require.Equal(t, "", response.Body.String())
+		require.True(t, iterator.closed)
+	})
+	t.Run("error_before_first_item", func(t *testing.T) {
+		response := stream("", countingIterator(0, NewHttpError(http.StatusForbidden, errors.New("no access"))))
+		require.Equal(t, http.StatusForbidden, response.Code)
+		require.Equal(t, "", response.Body.String())
+	})
+	t.Run("error_after_first_item", func(t *testing.T) {
+		response := stream("application/x-ndjson", countingIterator(2, errors.New("database\ngone")))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "{\"n\":1}\n{\"n\":2}\n", response.Body.String())
+		require.Equal(t, "database gone", response.Result().Trailer.Get(StreamErrorTrailer))
+	})
+}
+
+func TestChannelIterator(t *testing.T) {
+	t.Run("items", func(t *testing.T) {
+		channel := make(chan int, 2)
+		channel <- 1
+		channel <- 2
+		close(channel)
+
+		iterator := ChannelIterator(channel)
+		for _, expected := range []int{1, 2} {
+			item, ok, err := iterator.Next(context.Background())
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, expected, item)
+		}
+		_, ok, err := iterator.Next(context.Background())
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+	t.Run("error", func(t *testing.T) {
+		channel := make(chan interface{}, 1)
+		channel <- errors.New("bad")
+		_, ok, err := ChannelIterator(channel).Next(context.Background())
+		require.EqualError(t, err, "bad")
+		require.False(t, ok)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, ok, err := ChannelIterator(make(chan int)).Next(ctx)
+		require.Equal(t, context.Canceled, err)
+		require.False(t, ok)
+	})
+	t.Run("not_a_channel", func(t *testing.T) {
+		require.Panics(t, func() { ChannelIterator([]int{}) })
+		require.Panics(t, func() { ChannelIterator(make(chan<- int)) })
+	})
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"github.com/pkg/errors"
+	neturl "net/url"
+	"strings"
+)
+
+// Cross-checks the path parameters of each route against the bindings of its request struct, and verifies that every
+// registered method is bound to at least one route (WebSocket routes bind all methods).
+func (resolver *routesResolver) validate() {
+	boundMethods := make(map[string]bool)
+	for _, route := range resolver.routes {
+		if route.descriptor != nil {
+			boundMethods[route.descriptor.Name] = true
+		}
+		if h, ok := route.handler.(*handler); ok {
+			resolver.validateRouteBindings(route, h)
+		} else if _, ok := route.handler.(*webSocketHandler); ok {
+			for _, descriptor := range resolver.ms.Methods() {
+				boundMethods[descriptor.Name] = true
+			}
+		}
+	}
+
+	if !resolver.allowAllUnbound {
+		for _, descriptor := range resolver.ms.Methods() {
+			if !boundMethods[descriptor.Name] && !resolver.allowedUnbound[descriptor.Name] {
+				resolver.addProblem("method '%s' is not bound to any route", descriptor.Name)
+			}
+		}
+	}
+}
+
+func (resolver *routesResolver) validateRouteBindings(route *resolvedRoute, h *handler) {
+	requestType := h.methodAdapter.RequestType()
+	bindings, err := ParseBindings(requestType)
+	if err != nil {
+		resolver.addProblem("route '%s' has invalid request struct '%s': %s", route, requestType, err)
+		return
+	}
+
+	// Verify that each request parameter is bound by a single field
+	fieldsByParameter := make(map[string]string)
+	for _, b := range bindings {
+		if b.Kind == BindingBody {
+			continue
+		}
+		key := b.Kind + " parameter '" + b.Name + "'"
+		if other, ok := fieldsByParameter[key]; ok {
+			resolver.addProblem("fields '%s' and '%s' of '%s' are both bound to %s", other, b.Field.Name, requestType, key)
+		}
+		fieldsByParameter[key] = b.Field.Name
+	}
+
+	// Verify that path bindings & path parameters match
+	parameters := make([]string, 0)
+	declared := make(map[string]bool)
+	for _, parameter := range parsePatternParameters(route.pattern) {
+		parameters = append(parameters, parameter.name)
+		declared[parameter.name] = true
+	}
+	for _, b := range bindings {
+		if b.Kind == BindingPath && !declared[b.Name] {
+			resolver.addProblem("route '%s' is missing path parameter '{%s}' bound to field '%s' of '%s'", route, b.Name, b.Field.Name, requestType)
+		}
+	}
+	for _, parameter := range parameters {
+		if _, ok := fieldsByParameter[BindingPath+" parameter '"+parameter+"'"]; ok || parameter == "*" {
+			continue
+		}
+		problem := "route '%s' has path parameter '{%s}' not bound to any field of '%s'"
+		for _, kind := range []string{BindingQuery, BindingHeader, BindingCookie} {
+			if field, ok := fieldsByParameter[kind+" parameter '"+parameter+"'"]; ok {
+				problem += " (field '" + field + "' binds it as a " + kind + " parameter)"
+				break
+			}
+		}
+		resolver.addProblem(problem, route, parameter, requestType)
+	}
+}
+
+// A path parameter in a route pattern, along with its position in the pattern (including its braces, if any).
+type patternParameter struct {
+	name  string
+	start int
+	end   int
+}
+
+// Returns the parameters in the given route pattern (eg. "id" for "/users/{id:[0-9]+}"), including "*" if the pattern
+// ends with a wildcard.
+func parsePatternParameters(pattern string) []patternParameter {
+	parameters := make([]patternParameter, 0)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			// Find the matching closing brace, as regular expressions may contain braces as well
+			depth, end := 0, -1
+			for j := i; j < len(pattern) && end < 0; j++ {
+				switch pattern[j] {
+				case '{':
+					depth++
+				case '}':
+					if depth--; depth == 0 {
+						end = j
+					}
+				}
+			}
+			if end < 0 {
+				return parameters
+			}
+			name := pattern[i+1 : end]
+			if colon := strings.Index(name, ":"); colon >= 0 {
+				name = name[:colon]
+			}
+			parameters = append(parameters, patternParameter{name: name, start: i, end: end + 1})
+			i = end
+		case '*':
+			parameters = append(parameters, patternParameter{name: "*", start: i, end: i + 1})
+		}
+	}
+	return parameters
+}
+
+// Expands the given route pattern into a path, replacing each path parameter with its (escaped) value. Returns an error
+// if a parameter has no value.
+func ExpandPattern(pattern string, values map[string]string) (string, error) {
+	var sb strings.Builder
+	last := 0
+	for _, parameter := range parsePatternParameters(pattern) {
+		value, ok := values[parameter.name]
+		if !ok || value == "" {
+			return "", errors.Errorf("empty value for path parameter '%s'", parameter.name)
+		}
+		sb.WriteString(pattern[last:parameter.start])
+		if parameter.name == "*" {
+			sb.WriteString(value)
+		} else {
+			sb.WriteString(neturl.PathEscape(value))
+		}
+		last = parameter.end
+	}
+	sb.WriteString(pattern[last:])
+	return sb.String(), nil
+}
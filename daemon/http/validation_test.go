@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestParsePatternParameters(t *testing.T) {
+	require.Equal(t, []patternParameter{}, parsePatternParameters("/users"))
+	require.Equal(t, []patternParameter{{"id", 7, 11}}, parsePatternParameters("/users/{id}"))
+	require.Equal(t, []patternParameter{{"id", 7, 22}, {"name", 29, 35}}, parsePatternParameters("/users/{id:[0-9]{1,3}}/items/{name}"))
+	require.Equal(t, []patternParameter{{"org", 6, 11}, {"*", 18, 19}}, parsePatternParameters("/orgs/{org}/files/*"))
+}
+
+func TestValidateRoutes(t *testing.T) {
+	type UserReq struct {
+		Org  string `http:"path,org"`
+		ID   string `http:"path,id"`
+		Name string `http:"query,id"`
+	}
+	type DupReq struct {
+		A string `http:"query,q"`
+		B string `http:"query,q"`
+	}
+	type Res struct{}
+
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("GetUser", func(ctx context.Context, req *UserReq) (*Res, error) { return nil, nil })
+	ms.AddMethod("Search", func(ctx context.Context, req *DupReq) (*Res, error) { return nil, nil })
+	ms.AddMethod("Unrouted", func(ctx context.Context, req *struct{}) (*Res, error) { return nil, nil })
+	ms.AddMethod("Internal", func(ctx context.Context, req *struct{}) (*Res, error) { return nil, nil })
+
+	t.Run("valid", func(t *testing.T) {
+		routes := NewRoutes().AllowUnboundMethods("Search", "Unrouted", "Internal")
+		routes.Method(http.MethodGet, "/orgs/{org}/users/{id:[0-9]+}", "GetUser")
+		_, err := routes.resolve(ms)
+		require.NoError(t, err)
+	})
+	t.Run("mismatches", func(t *testing.T) {
+		routes := NewRoutes().AllowUnboundMethods("Internal")
+		routes.Group("/orgs/{org}").
+			Method(http.MethodGet, "/users", "GetUser").
+			Handle(http.MethodGet, "/search/{q}", NewHandler(ms.GetMethodAdapter("Search")))
+		_, err := routes.resolve(ms)
+		require.EqualError(t, err, "invalid HTTP routes:\n"+
+			"route 'GET /orgs/{org}/users' is missing path parameter '{id}' bound to field 'ID' of 'http.UserReq'\n"+
+			"fields 'A' and 'B' of 'http.DupReq' are both bound to query parameter 'q'\n"+
+			"route 'GET /orgs/{org}/search/{q}' has path parameter '{org}' not bound to any field of 'http.DupReq'\n"+
+			"route 'GET /orgs/{org}/search/{q}' has path parameter '{q}' not bound to any field of 'http.DupReq' (field 'B' binds it as a query parameter)\n"+
+			"method 'Unrouted' is not bound to any route")
+	})
+	t.Run("server_fails_fast", func(t *testing.T) {
+		_, err := NewHTTPServerWithRoutes(ms, &Config{}, NewRoutes().Method(http.MethodGet, "/users", "GetUser").AllowUnboundMethods())
+		require.EqualError(t, err, "invalid HTTP routes:\n"+
+			"route 'GET /users' is missing path parameter '{org}' bound to field 'Org' of 'http.UserReq'\n"+
+			"route 'GET /users' is missing path parameter '{id}' bound to field 'ID' of 'http.UserReq'")
+	})
+}
+
+func TestExpandPattern(t *testing.T) {
+	path, err := ExpandPattern("/orgs/{org}/users/{id:[0-9]+}/files/*", map[string]string{"org": "a b", "id": "1", "*": "x/y"})
+	require.NoError(t, err)
+	require.Equal(t, "/orgs/a%20b/users/1/files/x/y", path)
+
+	_, err = ExpandPattern("/users/{id}", map[string]string{})
+	require.EqualError(t, err, "empty value for path parameter 'id'")
+}
@@ -0,0 +1,489 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Interval of pings sent to WebSocket clients, unless configured otherwise; connections of clients not answering
+	// them (with a pong) within twice that interval are closed.
+	DefaultWebSocketPingInterval = 30 * time.Second
+
+	// Maximum size of messages received from WebSocket clients, unless configured otherwise.
+	DefaultWebSocketReadLimit = 1 << 20
+
+	// Maximum number of calls in flight over a single WebSocket connection, unless configured otherwise.
+	DefaultWebSocketMaxCalls = 100
+
+	// Timeout for writing a single message to a WebSocket client.
+	webSocketWriteTimeout = 10 * time.Second
+)
+
+// A JSON message exchanged over WebSocket connections, which is one of:
+//
+//   - a call sent by the client: {"id": "1", "method": "GetUser", "params": {...}}
+//   - a cancellation of a call, sent by the client: {"id": "1", "cancel": true}
+//   - the response to a call: {"id": "1", "result": {...}}, or {"id": "1", "error": {"code": 404, "message": "..."}}
+//   - an event pushed by the server: {"event": "userCreated", "data": {...}}
+//
+// Streamed responses (of streaming methods, or response bodies implementing Iterator) are sent as a message per item
+// with "more" set, followed by a final message without a result (or with the error the stream failed with).
+type WebSocketMessage struct {
+	ID     string          `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Cancel bool            `json:"cancel,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	More   bool            `json:"more,omitempty"`
+	Error  *WebSocketError `json:"error,omitempty"`
+	Event  string          `json:"event,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// An error a call failed with; its code is the HTTP status code of the error (see NewHttpError).
+type WebSocketError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Pushes events to the client of a WebSocket connection.
+type WebSocketPusher interface {
+	// Pushes the given event to the client, with the given data encoded as JSON. Fails if the connection is closed.
+	Push(event string, data interface{}) error
+
+	// Returns a channel that is closed when the connection is closed.
+	Done() <-chan struct{}
+}
+
+type webSocketPusherContextKey struct{}
+
+// Returns the pusher of the WebSocket connection over which the method serving the given context was called, or nil
+// if it was not called over a WebSocket connection. The pusher remains usable after the method returns, until the
+// connection is closed (eg. to push events to subscribers).
+func WebSocketPusherFrom(ctx context.Context) WebSocketPusher {
+	if pusher, ok := ctx.Value(webSocketPusherContextKey{}).(WebSocketPusher); ok {
+		return pusher
+	}
+	return nil
+}
+
+type WebSocketOption func(*webSocketHandler)
+
+// Allows WebSocket connections from the given origins (eg. "https://example.com", or "*" for any origin), in addition
+// to the same origin as the server.
+func WithWebSocketOrigins(origins ...string) WebSocketOption {
+	return func(h *webSocketHandler) {
+		h.origins = append(h.origins, origins...)
+	}
+}
+
+// Sets the interval of pings sent to WebSocket clients.
+func WithWebSocketPingInterval(interval time.Duration) WebSocketOption {
+	return func(h *webSocketHandler) {
+		h.pingInterval = interval
+	}
+}
+
+// Sets the maximum size of messages received from WebSocket clients; connections sending larger messages are closed.
+func WithWebSocketReadLimit(limit int64) WebSocketOption {
+	return func(h *webSocketHandler) {
+		h.readLimit = limit
+	}
+}
+
+// Sets the maximum number of calls in flight over a single WebSocket connection; further calls are answered with a 429
+// error (without being started) until some of them complete.
+func WithWebSocketMaxCalls(maxCalls int) WebSocketOption {
+	return func(h *webSocketHandler) {
+		h.maxCalls = maxCalls
+	}
+}
+
+type webSocketHandler struct {
+	origins      []string
+	pingInterval time.Duration
+	readLimit    int64
+	maxCalls     int
+	mutex        sync.Mutex
+	connections  map[*http.Server]map[*webSocketConnection]struct{}
+}
+
+// Creates a handler upgrading requests to WebSocket connections, over which clients can call any method of the
+// micro-service (see WebSocketMessage). Calls are dispatched through the method chains of the micro-service (so all
+// middleware applies), concurrently, and their responses are multiplexed with server pushes (see WebSocketPusherFrom)
+// over the same connection.
+func NewWebSocketHandler(options ...WebSocketOption) *webSocketHandler {
+	h := &webSocketHandler{
+		pingInterval: DefaultWebSocketPingInterval,
+		readLimit:    DefaultWebSocketReadLimit,
+		maxCalls:     DefaultWebSocketMaxCalls,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// Returns whether the given request originates from an allowed origin.
+func (h *webSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	if originURL, err := neturl.Parse(origin); err == nil {
+		return strings.EqualFold(originURL.Host, r.Host)
+	}
+	return false
+}
+
+// Tracks the given connection of the given request, until the returned function is called; since servers do not close
+// hijacked connections when they shut down, tracked connections are closed (with a going-away close frame) by a hook
+// registered on the server serving them.
+func (h *webSocketHandler) track(r *http.Request, c *webSocketConnection) func() {
+	server, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if !ok {
+		return func() {}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.connections == nil {
+		h.connections = make(map[*http.Server]map[*webSocketConnection]struct{})
+	}
+	connections, ok := h.connections[server]
+	if !ok {
+		connections = make(map[*webSocketConnection]struct{})
+		h.connections[server] = connections
+		server.RegisterOnShutdown(func() {
+			h.mutex.Lock()
+			closing := make([]*webSocketConnection, 0, len(connections))
+			for c := range connections {
+				closing = append(closing, c)
+			}
+			delete(h.connections, server)
+			h.mutex.Unlock()
+			for _, c := range closing {
+				c.goAway()
+			}
+		})
+	}
+	connections[c] = struct{}{}
+	return func() {
+		h.mutex.Lock()
+		delete(connections, c)
+		h.mutex.Unlock()
+	}
+}
+
+func (h *webSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	ms := msvc.GetFromContext(r.Context())
+	if ms == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	upgrader := &websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with an HTTP error
+		ms.Log("err", err, "msg", "failed upgrading WebSocket connection")
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &webSocketConnection{
+		ms:       ms,
+		conn:     conn,
+		ctx:      ctx,
+		cancel:   cancel,
+		outgoing: make(chan *WebSocketMessage),
+		calls:    make(map[string]context.CancelFunc),
+		maxCalls: h.maxCalls,
+	}
+	defer h.track(r, c)()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		c.writeMessages(h.pingInterval)
+	}()
+
+	c.readMessages(h.pingInterval, h.readLimit)
+	cancel()
+	c.inflight.Wait()
+	<-written
+}
+
+// A WebSocket connection, serving calls received from its client until it is closed.
+type webSocketConnection struct {
+	ms       *msvc.MicroService
+	conn     *websocket.Conn
+	ctx      context.Context
+	cancel   context.CancelFunc
+	outgoing chan *WebSocketMessage
+	mutex    sync.Mutex
+	calls    map[string]context.CancelFunc
+	maxCalls int
+	inflight sync.WaitGroup
+	leaving  bool
+}
+
+func (c *webSocketConnection) Push(event string, data interface{}) error {
+	encoded, err := encodeWebSocketData(data)
+	if err != nil {
+		return err
+	}
+	return c.send(&WebSocketMessage{Event: event, Data: encoded})
+}
+
+func (c *webSocketConnection) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Closes the connection with a going-away close frame (rather than a normal one), as the server is shutting down.
+func (c *webSocketConnection) goAway() {
+	c.mutex.Lock()
+	c.leaving = true
+	c.mutex.Unlock()
+	c.cancel()
+}
+
+// Queues the given message for sending, failing if the connection is closed.
+func (c *webSocketConnection) send(message *WebSocketMessage) error {
+	select {
+	case c.outgoing <- message:
+		return nil
+	case <-c.ctx.Done():
+		return errors.New("WebSocket connection closed")
+	}
+}
+
+// Sends the given error as the response of the given call, logging it.
+func (c *webSocketConnection) sendError(id string, err error) {
+	c.ms.Log("id", id, "err", err)
+	code, message := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	if httpErr, ok := err.(ErrHttp); ok {
+		code, message = httpErr.Code(), http.StatusText(httpErr.Code())
+		if httpErr.Cause() != nil {
+			message = httpErr.Cause().Error()
+		}
+	}
+	_ = c.send(&WebSocketMessage{ID: id, Error: &WebSocketError{Code: code, Message: message}})
+}
+
+// Writes queued messages to the connection, and pings the client periodically, until the connection is closed.
+func (c *webSocketConnection) writeMessages(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-c.ctx.Done():
+			code := websocket.CloseNormalClosure
+			c.mutex.Lock()
+			if c.leaving {
+				code = websocket.CloseGoingAway
+			}
+			c.mutex.Unlock()
+			closeMessage := websocket.FormatCloseMessage(code, "")
+			_ = c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(webSocketWriteTimeout))
+			return
+		case <-ticker.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteTimeout))
+		case message := <-c.outgoing:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+			err = c.conn.WriteJSON(message)
+		}
+		if err != nil {
+			c.ms.Log("err", err, "msg", "failed writing to WebSocket connection")
+			c.cancel()
+			_ = c.conn.Close() // unblocks the reader
+			return
+		}
+	}
+}
+
+// Reads & dispatches messages from the client, until the connection is closed (by either side).
+func (c *webSocketConnection) readMessages(pingInterval time.Duration, readLimit int64) {
+	c.conn.SetReadLimit(readLimit)
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+
+	for {
+		_, reader, err := c.conn.NextReader()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && c.ctx.Err() == nil {
+				c.ms.Log("err", err, "msg", "failed reading from WebSocket connection")
+			}
+			return
+		}
+
+		message := &WebSocketMessage{}
+		if err := json.NewDecoder(reader).Decode(message); err != nil {
+			c.sendError("", NewHttpError(http.StatusBadRequest, errors.Wrap(err, "failed reading message")))
+			continue
+		}
+		switch {
+		case message.ID == "":
+			c.sendError("", NewHttpError(http.StatusBadRequest, errors.New("message has no 'id'")))
+		case message.Cancel:
+			c.mutex.Lock()
+			if cancel, ok := c.calls[message.ID]; ok {
+				cancel()
+			}
+			c.mutex.Unlock()
+		case message.Method == "":
+			c.sendError(message.ID, NewHttpError(http.StatusBadRequest, errors.New("message has no 'method'")))
+		default:
+			c.call(message)
+		}
+	}
+}
+
+// Invokes the method of the given call in the background, and sends its response(s) when it completes. Calls cancelled
+// by the client get no further messages.
+func (c *webSocketConnection) call(message *WebSocketMessage) {
+	id := message.ID
+	method, adapter := c.ms.GetMethod(message.Method), c.ms.GetMethodAdapter(message.Method)
+	if method == nil || adapter == nil {
+		c.sendError(id, NewHttpError(http.StatusNotFound, errors.Errorf("method '%s' not found", message.Method)))
+		return
+	}
+
+	// Decode the request struct by JSON field names
+	request := reflect.New(adapter.RequestType())
+	if len(message.Params) > 0 && string(message.Params) != "null" {
+		if err := decoderFor(DefaultMediaType).Decode(bytes.NewReader(message.Params), request.Interface()); err != nil {
+			err = errors.Wrapf(err, "failed reading params into '%s'", adapter.RequestType().Name())
+			c.sendError(id, NewHttpError(http.StatusBadRequest, err))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(c.ctx, webSocketPusherContextKey{}, WebSocketPusher(c)))
+	c.mutex.Lock()
+	if _, ok := c.calls[id]; ok {
+		c.mutex.Unlock()
+		cancel()
+		c.sendError(id, NewHttpError(http.StatusConflict, errors.Errorf("call '%s' is already in progress", id)))
+		return
+	} else if len(c.calls) >= c.maxCalls {
+		c.mutex.Unlock()
+		cancel()
+		c.sendError(id, NewHttpError(http.StatusTooManyRequests, errors.Errorf("too many calls in progress (max %d)", c.maxCalls)))
+		return
+	}
+	c.calls[id] = cancel
+	c.mutex.Unlock()
+
+	c.inflight.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		defer func() {
+			c.mutex.Lock()
+			delete(c.calls, id)
+			c.mutex.Unlock()
+			cancel()
+		}()
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				c.sendError(id, errors.Errorf("method '%s' panicked: %v", message.Method, rvr))
+			}
+		}()
+
+		response, err := method(ctx, request.Elem().Interface())
+		if ctx.Err() != nil && c.ctx.Err() == nil {
+			return // cancelled by the client
+		}
+		c.respond(ctx, id, adapter, response, err)
+	}()
+}
+
+// Sends the given response of a call, or the error it failed with, streaming it if it is a stream.
+func (c *webSocketConnection) respond(ctx context.Context, id string, adapter msvc.MethodAdapter, response interface{}, err error) {
+	if err != nil {
+		c.sendError(id, err)
+		return
+	}
+
+	// Streaming methods stream their responses; others might stream their response body
+	body := response
+	if _, ok := response.(msvc.Stream); !ok && response != nil {
+		encoder, err := newResponseEncoder(adapter.ResponseType())
+		if err != nil {
+			c.sendError(id, err)
+			return
+		}
+		body = encoder.split(response).body
+	}
+	if iterator, ok := body.(Iterator); ok {
+		c.stream(ctx, id, iterator)
+		return
+	}
+
+	result, err := encodeWebSocketData(body)
+	if err != nil {
+		c.sendError(id, err)
+		return
+	}
+	_ = c.send(&WebSocketMessage{ID: id, Result: result})
+}
+
+// Sends the items of the given iterator as responses of a call, followed by a final response.
+func (c *webSocketConnection) stream(ctx context.Context, id string, iterator Iterator) {
+	if closer, ok := iterator.(io.Closer); ok {
+		defer closer.Close()
+	}
+	for {
+		item, ok, err := iterator.Next(ctx)
+		if ctx.Err() != nil && c.ctx.Err() == nil {
+			return // cancelled by the client
+		} else if err != nil {
+			c.sendError(id, err)
+			return
+		} else if !ok {
+			_ = c.send(&WebSocketMessage{ID: id})
+			return
+		}
+
+		result, err := encodeWebSocketData(item)
+		if err != nil {
+			c.sendError(id, err)
+			return
+		}
+		if err := c.send(&WebSocketMessage{ID: id, Result: result, More: true}); err != nil {
+			return
+		}
+	}
+}
+
+// Encodes the given value as JSON (using the registered JSON encoder), or returns nil for nil values.
+func encodeWebSocketData(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	buffer := new(bytes.Buffer)
+	if err := encoderFor(DefaultMediaType).Encode(buffer, v, false); err != nil {
+		return nil, errors.Wrap(err, "failed encoding JSON")
+	}
+	return json.RawMessage(bytes.TrimRight(buffer.Bytes(), "\n")), nil
+}
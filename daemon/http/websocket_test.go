@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/arikkfir/msvc"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type webSocketTestRequest struct {
+	Name string `http:"query,name" json:"name"`
+}
+
+type webSocketTestResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+type webSocketTestEvent struct {
+	N int `json:"n"`
+}
+
+// Starts a server with a WebSocket route (and no other routes) over a micro-service with test methods, returning the
+// server & a connected client.
+func newWebSocketTestServer(t *testing.T, options ...WebSocketOption) (*httptest.Server, *websocket.Conn) {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("Greet", func(ctx context.Context, req *webSocketTestRequest) (*webSocketTestResponse, error) {
+		if req.Name == "" {
+			return nil, NewHttpError(http.StatusBadRequest, errors.New("name is required"))
+		}
+		return &webSocketTestResponse{Greeting: "Hello " + req.Name}, nil
+	})
+	ms.AddMethod("Fail", func(ctx context.Context, req *webSocketTestRequest) (*webSocketTestResponse, error) {
+		return nil, errors.New("secret failure")
+	})
+	ms.AddMethod("Count", func(ctx context.Context, req *webSocketTestRequest, send func(*webSocketTestEvent) error) error {
+		for i := 1; i <= 2; i++ {
+			if err := send(&webSocketTestEvent{i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	ms.AddMethod("Forever", func(ctx context.Context, req *webSocketTestRequest, send func(*webSocketTestEvent) error) error {
+		for i := 1; ; i++ {
+			if err := send(&webSocketTestEvent{i}); err != nil {
+				return err
+			}
+		}
+	})
+	ms.AddMethod("Subscribe", func(ctx context.Context, req *webSocketTestRequest) (*webSocketTestResponse, error) {
+		pusher := WebSocketPusherFrom(ctx)
+		if pusher == nil {
+			return nil, errors.New("no pusher")
+		}
+		go func() {
+			_ = pusher.Push("greeted", &webSocketTestResponse{Greeting: "Hi " + req.Name})
+		}()
+		return &webSocketTestResponse{Greeting: "subscribed"}, nil
+	})
+	ms.AddMiddleware(func(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := method(ctx, request)
+			if greeting, ok := response.(*webSocketTestResponse); ok {
+				greeting.Greeting += "!"
+			}
+			return response, err
+		}
+	})
+
+	router, err := createRouter(ms, &Config{}, NewRoutes().WebSocket("/ws", options...))
+	require.NoError(t, err)
+	server := httptest.NewServer(router)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		server.Close()
+		require.NoError(t, err)
+	}
+	return server, conn
+}
+
+// Reads the next message from the given connection, failing the test if none arrives within a second.
+func readWebSocketMessage(t *testing.T, conn *websocket.Conn) *WebSocketMessage {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	message := &WebSocketMessage{}
+	require.NoError(t, conn.ReadJSON(message))
+	return message
+}
+
+func TestWebSocket(t *testing.T) {
+	server, conn := newWebSocketTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+	call := func(id, method, params string) {
+		message := &WebSocketMessage{ID: id, Method: method}
+		if params != "" {
+			message.Params = json.RawMessage(params)
+		}
+		require.NoError(t, conn.WriteJSON(message))
+	}
+
+	t.Run("call", func(t *testing.T) {
+		call("1", "Greet", `{"name": "Jack"}`)
+		response := readWebSocketMessage(t, conn)
+		require.Equal(t, "1", response.ID)
+		require.Nil(t, response.Error)
+		require.False(t, response.More)
+		require.JSONEq(t, `{"greeting": "Hello Jack!"}`, string(response.Result))
+	})
+	t.Run("method_error", func(t *testing.T) {
+		call("2", "Greet", "")
+		require.Equal(t, &WebSocketMessage{ID: "2", Error: &WebSocketError{400, "name is required"}}, readWebSocketMessage(t, conn))
+
+		call("3", "Fail", "")
+		require.Equal(t, &WebSocketMessage{ID: "3", Error: &WebSocketError{500, "Internal Server Error"}}, readWebSocketMessage(t, conn))
+	})
+	t.Run("bad_calls", func(t *testing.T) {
+		call("4", "Unknown", "")
+		require.Equal(t, &WebSocketMessage{ID: "4", Error: &WebSocketError{404, "method 'Unknown' not found"}}, readWebSocketMessage(t, conn))
+
+		call("5", "Greet", `{"unknown": 1}`)
+		response := readWebSocketMessage(t, conn)
+		require.Equal(t, 400, response.Error.Code)
+		require.Contains(t, response.Error.Message, "failed reading params into 'webSocketTestRequest'")
+
+		call("", "Greet", "")
+		require.Equal(t, &WebSocketMessage{Error: &WebSocketError{400, "message has no 'id'"}}, readWebSocketMessage(t, conn))
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+		response = readWebSocketMessage(t, conn)
+		require.Equal(t, 400, response.Error.Code)
+	})
+	t.Run("stream", func(t *testing.T) {
+		call("6", "Count", "")
+		for _, expected := range []string{`{"n": 1}`, `{"n": 2}`} {
+			response := readWebSocketMessage(t, conn)
+			require.Equal(t, "6", response.ID)
+			require.True(t, response.More)
+			require.JSONEq(t, expected, string(response.Result))
+		}
+		require.Equal(t, &WebSocketMessage{ID: "6"}, readWebSocketMessage(t, conn))
+	})
+	t.Run("cancel", func(t *testing.T) {
+		call("7", "Forever", "")
+		require.True(t, readWebSocketMessage(t, conn).More)
+		require.NoError(t, conn.WriteJSON(&WebSocketMessage{ID: "7", Cancel: true}))
+
+		// Drain responses sent before the cancellation was received, until the next call's response
+		call("8", "Greet", `{"name": "Jill"}`)
+		for {
+			response := readWebSocketMessage(t, conn)
+			if response.ID == "8" {
+				require.JSONEq(t, `{"greeting": "Hello Jill!"}`, string(response.Result))
+				break
+			}
+			require.Equal(t, "7", response.ID)
+			require.True(t, response.More)
+		}
+	})
+	t.Run("push", func(t *testing.T) {
+		call("9", "Subscribe", `{"name": "Jack"}`)
+		messages := map[string]*WebSocketMessage{}
+		for i := 0; i < 2; i++ {
+			message := readWebSocketMessage(t, conn)
+			messages[message.ID+message.Event] = message
+		}
+		require.JSONEq(t, `{"greeting": "subscribed!"}`, string(messages["9"].Result))
+		require.JSONEq(t, `{"greeting": "Hi Jack"}`, string(messages["greeted"].Data))
+	})
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	dial := func(server *httptest.Server, origin string) (*websocket.Conn, *http.Response, error) {
+		header := http.Header{}
+		header.Set("Origin", origin)
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", header)
+	}
+
+	server, conn := newWebSocketTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+	_, response, err := dial(server, "http://example.com")
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, response.StatusCode)
+	sameOrigin, _, err := dial(server, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, sameOrigin.Close())
+
+	allowingServer, allowingConn := newWebSocketTestServer(t, WithWebSocketOrigins("http://example.com"))
+	defer allowingServer.Close()
+	defer allowingConn.Close()
+	allowed, _, err := dial(allowingServer, "http://example.com")
+	require.NoError(t, err)
+	require.NoError(t, allowed.Close())
+}
+
+func TestWebSocketServerShutdown(t *testing.T) {
+	server, conn := newWebSocketTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	// Complete a call first, so the connection is known to be served
+	require.NoError(t, conn.WriteJSON(&WebSocketMessage{ID: "1", Method: "Greet", Params: json.RawMessage(`{"name":"Jack"}`)}))
+	require.Equal(t, "1", readWebSocketMessage(t, conn).ID)
+
+	// Shutting down the server closes the connection (rather than waiting for the client to disconnect)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Config.Shutdown(ctx) }()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, _, err := conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+	require.NoError(t, <-shutdown)
+}
+
+func TestWebSocketMaxCalls(t *testing.T) {
+	server, conn := newWebSocketTestServer(t, WithWebSocketMaxCalls(1))
+	defer server.Close()
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(&WebSocketMessage{ID: "1", Method: "Forever"}))
+	require.True(t, readWebSocketMessage(t, conn).More)
+	require.NoError(t, conn.WriteJSON(&WebSocketMessage{ID: "2", Method: "Greet", Params: json.RawMessage(`{"name":"Jack"}`)}))
+	for {
+		response := readWebSocketMessage(t, conn)
+		if response.ID == "2" {
+			require.Nil(t, response.Result)
+			require.Equal(t, &WebSocketError{Code: http.StatusTooManyRequests, Message: "too many calls in progress (max 1)"}, response.Error)
+			break
+		}
+		require.Equal(t, "1", response.ID)
+	}
+}
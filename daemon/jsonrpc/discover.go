@@ -0,0 +1,105 @@
+package jsonrpc
+
+import (
+	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The OpenRPC version of documents returned by the discovery method.
+const OpenRPCVersion = "1.2.6"
+
+// An OpenRPC document, describing the methods served by the JSON-RPC endpoint.
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []*OpenRPCMethod  `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenRPCMethod struct {
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description,omitempty"`
+	Tags           []*OpenRPCTag               `json:"tags,omitempty"`
+	Deprecated     bool                        `json:"deprecated,omitempty"`
+	ParamStructure string                      `json:"paramStructure"`
+	Params         []*OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor   `json:"result"`
+}
+
+type OpenRPCTag struct {
+	Name string `json:"name"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name     string        `json:"name"`
+	Required bool          `json:"required,omitempty"`
+	Schema   *httpd.Schema `json:"schema"`
+}
+
+type OpenRPCComponents struct {
+	Schemas map[string]*httpd.Schema `json:"schemas,omitempty"`
+}
+
+// Returns an OpenRPC document describing all methods of the given micro-service. Params of each method are the
+// properties of its request struct (as encoded by "encoding/json"), and its result is its response struct (or an array
+// of its responses, for streaming methods).
+func discover(ms *msvc.MicroService) *OpenRPCDocument {
+	const refPrefix = "#/components/schemas/"
+	descriptors := ms.Methods()
+	types := make([]reflect.Type, 0, 2*len(descriptors))
+	for _, descriptor := range descriptors {
+		types = append(types, descriptor.Adapter.RequestType(), descriptor.Adapter.ResponseType())
+	}
+	schemas, definitions := httpd.TypeSchemas(refPrefix, types...)
+
+	document := &OpenRPCDocument{
+		OpenRPC: OpenRPCVersion,
+		Info:    OpenRPCInfo{Title: ms.Name(), Version: "0.0.0"},
+		Methods: make([]*OpenRPCMethod, 0, len(descriptors)),
+	}
+	for i, descriptor := range descriptors {
+		method := &OpenRPCMethod{
+			Name:           descriptor.Name,
+			Description:    descriptor.Description,
+			Deprecated:     descriptor.Deprecated,
+			ParamStructure: "by-name",
+			Params:         make([]*OpenRPCContentDescriptor, 0),
+			Result:         &OpenRPCContentDescriptor{Name: "result", Schema: schemas[2*i+1]},
+		}
+		for _, tag := range descriptor.Tags {
+			method.Tags = append(method.Tags, &OpenRPCTag{Name: tag})
+		}
+		if msvc.IsStreaming(descriptor.Adapter) {
+			method.Result.Schema = &httpd.Schema{Type: "array", Items: method.Result.Schema}
+		}
+
+		// Describe each property of the request struct as a param, in order of name
+		requestSchema := schemas[2*i]
+		if requestSchema.Ref != "" {
+			requestSchema = definitions[strings.TrimPrefix(requestSchema.Ref, refPrefix)]
+		}
+		if requestSchema != nil {
+			required := make(map[string]bool)
+			for _, name := range requestSchema.Required {
+				required[name] = true
+			}
+			for name, schema := range requestSchema.Properties {
+				method.Params = append(method.Params, &OpenRPCContentDescriptor{Name: name, Required: required[name], Schema: schema})
+			}
+			sort.Slice(method.Params, func(i, j int) bool { return method.Params[i].Name < method.Params[j].Name })
+		}
+		document.Methods = append(document.Methods, method)
+	}
+	if len(definitions) > 0 {
+		document.Components.Schemas = definitions
+	}
+	return document
+}
@@ -0,0 +1,50 @@
+package jsonrpc
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	response := post(t, newTestMicroService(t), `{"jsonrpc": "2.0", "method": "rpc.discover", "id": 1}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `{
+		"jsonrpc": "2.0",
+		"id": 1,
+		"result": {
+			"openrpc": "1.2.6",
+			"info": {"title": "test", "version": "0.0.0"},
+			"methods": [
+				{
+					"name": "Count",
+					"paramStructure": "by-name",
+					"params": [],
+					"result": {"name": "result", "schema": {"type": "array", "items": {"$ref": "#/components/schemas/countEvent"}}}
+				},
+				{
+					"name": "Greet",
+					"description": "Greets someone",
+					"tags": [{"name": "greetings"}],
+					"paramStructure": "by-name",
+					"params": [
+						{"name": "name", "required": true, "schema": {"type": "string"}},
+						{"name": "title", "schema": {"type": "string"}}
+					],
+					"result": {"name": "result", "schema": {"$ref": "#/components/schemas/greetResponse"}}
+				}
+			],
+			"components": {
+				"schemas": {
+					"countEvent": {"type": "object", "properties": {"n": {"type": "integer", "format": "int64"}}, "required": ["n"]},
+					"greetRequest": {
+						"type": "object",
+						"properties": {"name": {"type": "string"}, "title": {"type": "string"}},
+						"required": ["name"]
+					},
+					"greetResponse": {"type": "object", "properties": {"greeting": {"type": "string"}}, "required": ["greeting"]}
+				}
+			}
+		}
+	}`, response.Body.String())
+}
@@ -0,0 +1,79 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"net/http"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+
+	// Code of errors reported by methods that are neither invalid params nor internal errors (eg. HTTP errors with
+	// 4xx status codes other than 400 & 422).
+	ServerError = -32000
+)
+
+// An error object of a JSON-RPC response. Methods can return (or wrap) it to report a specific error code & data.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+func NewError(code int, message string, data interface{}) *Error {
+	return &Error{code, message, data}
+}
+
+// The data of errors mapped from HTTP errors (see httpd.NewHttpError).
+type HTTPErrorData struct {
+	Status int `json:"status"`
+}
+
+// Maps the given method error to a JSON-RPC error object:
+//
+//   - *Error values are reported as is
+//   - HTTP errors with status 400 or 422 are invalid params, 5xx statuses are internal errors, and other statuses are
+//     server errors; all are reported with their cause as the message, and their status in the data
+//   - cancelled or timed-out contexts are server errors
+//   - other errors are internal errors, whose message is not exposed to clients
+func errorOf(err error) *Error {
+	// Unwrap the error until a typed one is found (HTTP errors have causes too, so errors.Cause does not suffice)
+	for err != nil {
+		switch typed := err.(type) {
+		case *Error:
+			return typed
+		case httpd.ErrHttp:
+			message := http.StatusText(typed.Code())
+			if typed.Cause() != nil {
+				message = typed.Cause().Error()
+			}
+			code := ServerError
+			if typed.Code() == http.StatusBadRequest || typed.Code() == http.StatusUnprocessableEntity {
+				code = InvalidParams
+			} else if typed.Code() >= http.StatusInternalServerError {
+				code = InternalError
+			}
+			return &Error{Code: code, Message: message, Data: &HTTPErrorData{Status: typed.Code()}}
+		}
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return &Error{Code: ServerError, Message: err.Error()}
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+	return &Error{Code: InternalError, Message: "Internal error"}
+}
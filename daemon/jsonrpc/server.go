@@ -0,0 +1,299 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	// The JSON-RPC protocol version.
+	Version = "2.0"
+
+	// Path to serve the JSON-RPC endpoint at, unless configured otherwise.
+	DefaultPath = "/"
+
+	// Name of the introspection method, returning an OpenRPC document describing all methods (see OpenRPCDocument).
+	DiscoverMethod = "rpc.discover"
+
+	// Maximum size of request bodies (in bytes), unless configured otherwise.
+	DefaultMaxBodySize = 1 << 20
+
+	// Maximum number of requests in a batch, unless configured otherwise.
+	DefaultMaxBatchSize = 100
+)
+
+type Config struct {
+	Port uint16
+
+	// Path to serve the JSON-RPC endpoint at; defaults to "/".
+	Path string
+
+	// Maximum size of request bodies (in bytes); defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+
+	// Maximum number of requests in a batch; defaults to DefaultMaxBatchSize.
+	MaxBatchSize int
+}
+
+// A JSON-RPC request; requests without an ID are notifications, which get no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// A JSON-RPC response, carrying either the result of the call or its error.
+type Response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+// Creates the JSON-RPC server daemon, serving all methods of the given micro-service at a single endpoint.
+func NewJSONRPCServer(ms *msvc.MicroService, config *Config) msvc.Daemon {
+	path := config.Path
+	if path == "" {
+		path = DefaultPath
+	}
+	options := make([]HandlerOption, 0)
+	if config.MaxBodySize > 0 {
+		options = append(options, WithMaxBodySize(config.MaxBodySize))
+	}
+	if config.MaxBatchSize > 0 {
+		options = append(options, WithMaxBatchSize(config.MaxBatchSize))
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, NewHandler(ms, options...))
+	return httpd.NewServer("jsonrpc", fmt.Sprintf(":%d", config.Port), mux)
+}
+
+type HandlerOption func(*handler)
+
+// Sets the maximum size of request bodies (in bytes); larger requests are rejected with HTTP 413.
+func WithMaxBodySize(size int64) HandlerOption {
+	return func(h *handler) {
+		h.maxBodySize = size
+	}
+}
+
+// Sets the maximum number of requests in a batch; larger batches are rejected with an invalid request error.
+func WithMaxBatchSize(size int) HandlerOption {
+	return func(h *handler) {
+		h.maxBatchSize = size
+	}
+}
+
+type handler struct {
+	ms           *msvc.MicroService
+	maxBodySize  int64
+	maxBatchSize int
+}
+
+// Creates an HTTP handler serving all methods of the given micro-service over JSON-RPC 2.0 (via "POST" requests),
+// including batches & notifications. Calls are dispatched through the method chains of the micro-service (so all
+// middleware applies), and their requests are decoded from the call params by JSON field names; params must be given
+// by name (as an object). Streaming methods respond with an array of all streamed responses.
+func NewHandler(ms *msvc.MicroService, options ...HandlerOption) http.Handler {
+	h := &handler{ms: ms, maxBodySize: DefaultMaxBodySize, maxBatchSize: DefaultMaxBatchSize}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		h.ms.Log("err", err, "msg", "failed reading JSON-RPC request")
+		if int64(len(body)) >= h.maxBodySize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+	ctx := msvc.SetInContext(r.Context(), h.ms)
+
+	// Single requests
+	if trimmed := bytes.TrimSpace(body); len(trimmed) == 0 || trimmed[0] != '[' {
+		if response := h.call(ctx, body); response != nil {
+			h.writeResponse(w, response)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	// Batches are served concurrently, and their responses (if any) are returned in order
+	batch := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(body, &batch); err != nil {
+		h.writeResponse(w, errorResponse(nil, NewError(ParseError, "Parse error", nil)))
+		return
+	} else if len(batch) == 0 {
+		h.writeResponse(w, errorResponse(nil, NewError(InvalidRequest, "Invalid Request", nil)))
+		return
+	} else if len(batch) > h.maxBatchSize {
+		data := fmt.Sprintf("batches are limited to %d requests", h.maxBatchSize)
+		h.writeResponse(w, errorResponse(nil, NewError(InvalidRequest, "Invalid Request", data)))
+		return
+	}
+	responses := make([]*Response, len(batch))
+	var wg sync.WaitGroup
+	for i := range batch {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = h.call(ctx, batch[i])
+		}(i)
+	}
+	wg.Wait()
+
+	results := make([]*Response, 0, len(responses))
+	for _, response := range responses {
+		if response != nil {
+			results = append(results, response)
+		}
+	}
+	if len(results) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.writeResponse(w, results)
+}
+
+// Writes the given response (or batch of responses).
+func (h *handler) writeResponse(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	if h.ms.Environment() != msvc.EnvProduction {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(response); err != nil {
+		h.ms.Log("err", err, "msg", "failed encoding JSON-RPC response")
+	}
+}
+
+// Serves the given request, returning its response, or nil for notifications.
+func (h *handler) call(ctx context.Context, body []byte) *Response {
+	request := &Request{}
+	if err := json.Unmarshal(body, request); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok || len(bytes.TrimSpace(body)) == 0 {
+			return errorResponse(nil, NewError(ParseError, "Parse error", nil))
+		}
+		return errorResponse(nil, NewError(InvalidRequest, "Invalid Request", nil))
+	}
+	if !validID(request.ID) {
+		return errorResponse(nil, NewError(InvalidRequest, "Invalid Request", nil))
+	} else if request.JSONRPC != Version || request.Method == "" {
+		return errorResponse(request.ID, NewError(InvalidRequest, "Invalid Request", nil))
+	}
+
+	result, err := h.invoke(ctx, request)
+	if err == nil {
+		var encoded []byte
+		if encoded, err = json.Marshal(result); err == nil {
+			if request.ID == nil {
+				return nil
+			}
+			raw := json.RawMessage(encoded)
+			return &Response{JSONRPC: Version, Result: &raw, ID: request.ID}
+		}
+		err = errors.Wrapf(err, "failed encoding result of '%s'", request.Method)
+	}
+
+	h.ms.Log("method", request.Method, "err", err)
+	if request.ID == nil {
+		return nil
+	}
+	return errorResponse(request.ID, errorOf(err))
+}
+
+// Invokes the method of the given request, returning its result.
+func (h *handler) invoke(ctx context.Context, request *Request) (result interface{}, err error) {
+	if request.Method == DiscoverMethod {
+		return discover(h.ms), nil
+	}
+
+	method, adapter := h.ms.GetMethod(request.Method), h.ms.GetMethodAdapter(request.Method)
+	if method == nil || adapter == nil || strings.HasPrefix(request.Method, "rpc.") {
+		return nil, NewError(MethodNotFound, "Method not found", nil)
+	}
+
+	// Decode the request struct from params (given by name) using JSON field names
+	requestValue := reflect.New(adapter.RequestType())
+	if params := bytes.TrimSpace(request.Params); len(params) > 0 && !bytes.Equal(params, []byte("null")) {
+		if params[0] != '{' {
+			return nil, NewError(InvalidParams, "Invalid params", "params must be given by name")
+		}
+		decoder := json.NewDecoder(bytes.NewReader(params))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(requestValue.Interface()); err != nil {
+			return nil, NewError(InvalidParams, "Invalid params", err.Error())
+		}
+	}
+
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			result, err = nil, errors.Errorf("method '%s' panicked: %v", request.Method, rvr)
+		}
+	}()
+	response, err := method(ctx, requestValue.Elem().Interface())
+	if stream, ok := response.(msvc.Stream); ok && err == nil {
+		return collect(ctx, stream)
+	}
+	return response, err
+}
+
+// Returns all responses of the given stream.
+func collect(ctx context.Context, stream msvc.Stream) ([]interface{}, error) {
+	defer stream.Close()
+	responses := make([]interface{}, 0)
+	for {
+		response, ok, err := stream.Next(ctx)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return responses, nil
+		}
+		responses = append(responses, response)
+	}
+}
+
+// Returns whether the given request ID is valid: a string, a number, null, or missing (for notifications).
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	var value interface{}
+	if err := json.Unmarshal(id, &value); err != nil {
+		return false
+	}
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func errorResponse(id json.RawMessage, err *Error) *Response {
+	return &Response{JSONRPC: Version, Error: err, ID: id}
+}
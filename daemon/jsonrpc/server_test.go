@@ -0,0 +1,208 @@
+package jsonrpc
+
+import (
+	"context"
+	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greetRequest struct {
+	Name  string `json:"name"`
+	Title string `json:"title,omitempty"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+type countEvent struct {
+	N int `json:"n"`
+}
+
+func newTestMicroService(t *testing.T) *msvc.MicroService {
+	ms, err := msvc.New("test", &struct{}{})
+	require.NoError(t, err)
+	ms.AddMethod("Greet", func(ctx context.Context, req *greetRequest) (*greetResponse, error) {
+		switch req.Name {
+		case "":
+			return nil, httpd.NewHttpError(http.StatusBadRequest, errors.New("name is required"))
+		case "nobody":
+			return nil, httpd.NewHttpError(http.StatusNotFound, errors.New("nobody not found"))
+		case "custom":
+			return nil, errors.Wrap(NewError(42, "custom failure", "details"), "failed greeting")
+		case "secret":
+			return nil, errors.New("secret failure")
+		case "panic":
+			panic("bad")
+		}
+		return &greetResponse{Greeting: strings.Join(strings.Fields("Hello "+req.Title+" "+req.Name), " ")}, nil
+	}, msvc.WithDescription("Greets someone"), msvc.WithTags("greetings"))
+	ms.AddMethod("Count", func(ctx context.Context, req *struct{}, send func(*countEvent) error) error {
+		for i := 1; i <= 2; i++ {
+			if err := send(&countEvent{i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	ms.AddMiddleware(func(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := method(ctx, request)
+			if greeting, ok := response.(*greetResponse); ok {
+				greeting.Greeting += "!"
+			}
+			return response, err
+		}
+	})
+	return ms
+}
+
+func post(t *testing.T, ms *msvc.MicroService, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "http://localhost:3001", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	NewHandler(ms).ServeHTTP(response, request)
+	return response
+}
+
+func TestHandler(t *testing.T) {
+	ms := newTestMicroService(t)
+
+	t.Run("call", func(t *testing.T) {
+		response := post(t, ms, `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack", "title": "Mr."}, "id": 1}`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "application/json", response.Header().Get("content-type"))
+		require.JSONEq(t, `{"jsonrpc": "2.0", "result": {"greeting": "Hello Mr. Jack!"}, "id": 1}`, response.Body.String())
+	})
+	t.Run("null_id", func(t *testing.T) {
+		response := post(t, ms, `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack"}, "id": null}`)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "result": {"greeting": "Hello Jack!"}, "id": null}`, response.Body.String())
+	})
+	t.Run("notification", func(t *testing.T) {
+		response := post(t, ms, `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack"}}`)
+		require.Equal(t, http.StatusNoContent, response.Code)
+		require.Equal(t, "", response.Body.String())
+
+		response = post(t, ms, `{"jsonrpc": "2.0", "method": "Unknown"}`)
+		require.Equal(t, http.StatusNoContent, response.Code)
+	})
+	t.Run("streaming", func(t *testing.T) {
+		response := post(t, ms, `{"jsonrpc": "2.0", "method": "Count", "id": "c"}`)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "result": [{"n": 1}, {"n": 2}], "id": "c"}`, response.Body.String())
+	})
+	t.Run("errors", func(t *testing.T) {
+		for name, tc := range map[string]struct{ body, expected string }{
+			"parse_error": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet"`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "Parse error"}, "id": null}`,
+			},
+			"invalid_request": {
+				body:     `{"jsonrpc": "1.0", "method": "Greet", "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": 1}`,
+			},
+			"invalid_id": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "id": {}}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null}`,
+			},
+			"method_not_found": {
+				body:     `{"jsonrpc": "2.0", "method": "Unknown", "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": 1}`,
+			},
+			"reserved_method": {
+				body:     `{"jsonrpc": "2.0", "method": "rpc.unknown", "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": 1}`,
+			},
+			"positional_params": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": ["Jack"], "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "Invalid params", "data": "params must be given by name"}, "id": 1}`,
+			},
+			"unknown_param": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {"nickname": "J"}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "Invalid params", "data": "json: unknown field \"nickname\""}, "id": 1}`,
+			},
+			"http_bad_request": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32602, "message": "name is required", "data": {"status": 400}}, "id": 1}`,
+			},
+			"http_not_found": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "nobody"}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32000, "message": "nobody not found", "data": {"status": 404}}, "id": 1}`,
+			},
+			"custom_error": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "custom"}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": 42, "message": "custom failure", "data": "details"}, "id": 1}`,
+			},
+			"internal_error": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "secret"}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "Internal error"}, "id": 1}`,
+			},
+			"panic": {
+				body:     `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "panic"}, "id": 1}`,
+				expected: `{"jsonrpc": "2.0", "error": {"code": -32603, "message": "Internal error"}, "id": 1}`,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				response := post(t, ms, tc.body)
+				require.Equal(t, http.StatusOK, response.Code)
+				require.JSONEq(t, tc.expected, response.Body.String())
+			})
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		response := post(t, ms, `[
+			{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack"}, "id": "1"},
+			{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jill"}},
+			1,
+			{"jsonrpc": "2.0", "method": "Unknown", "id": "2"},
+			{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jill"}, "id": "3"}
+		]`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `[
+			{"jsonrpc": "2.0", "result": {"greeting": "Hello Jack!"}, "id": "1"},
+			{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null},
+			{"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": "2"},
+			{"jsonrpc": "2.0", "result": {"greeting": "Hello Jill!"}, "id": "3"}
+		]`, response.Body.String())
+	})
+	t.Run("bad_batches", func(t *testing.T) {
+		response := post(t, ms, `[]`)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request"}, "id": null}`, response.Body.String())
+
+		response = post(t, ms, `[{"jsonrpc": "2.0", "method": "Greet"},`)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "Parse error"}, "id": null}`, response.Body.String())
+
+		response = post(t, ms, `[{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack"}}]`)
+		require.Equal(t, http.StatusNoContent, response.Code)
+	})
+	t.Run("limits", func(t *testing.T) {
+		handler := NewHandler(ms, WithMaxBodySize(512), WithMaxBatchSize(2))
+		serve := func(body string) *httptest.ResponseRecorder {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "http://localhost:3001", strings.NewReader(body)))
+			return response
+		}
+
+		call := `{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "Jack"}}`
+		response := serve(`[` + call + `,` + call + `,` + call + `]`)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32600, "message": "Invalid Request", "data": "batches are limited to 2 requests"}, "id": null}`, response.Body.String())
+
+		response = serve(`{"jsonrpc": "2.0", "method": "Greet", "params": {"name": "` + strings.Repeat("x", 512) + `"}, "id": 1}`)
+		require.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+
+		response = serve(`[` + call + `,` + call + `]`)
+		require.Equal(t, http.StatusNoContent, response.Code)
+	})
+	t.Run("method_not_allowed", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:3001", nil)
+		response := httptest.NewRecorder()
+		NewHandler(ms).ServeHTTP(response, request)
+		require.Equal(t, http.StatusMethodNotAllowed, response.Code)
+		require.Equal(t, http.MethodPost, response.Header().Get("allow"))
+	})
+}
@@ -0,0 +1,92 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFuncDaemon(t *testing.T) {
+	t.Run("lifecycle", func(t *testing.T) {
+		started := make(chan struct{})
+		daemon := NewDaemon("test", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return nil
+		})
+		require.Equal(t, "test", daemon.Name())
+		require.Equal(t, DaemonStopped, daemon.Status())
+		require.NoError(t, daemon.Stop(context.Background()))
+
+		result := make(chan error, 1)
+		go func() { result <- daemon.Start(context.Background()) }()
+		<-started
+		require.Equal(t, DaemonReady, daemon.Status())
+		require.EqualError(t, daemon.Start(context.Background()), "daemon 'test' already started")
+
+		require.NoError(t, daemon.Stop(context.Background()))
+		require.NoError(t, <-result)
+		require.Equal(t, DaemonStopped, daemon.Status())
+	})
+	t.Run("failure", func(t *testing.T) {
+		daemon := NewDaemon("test", func(ctx context.Context) error { return errors.New("bad") })
+		require.EqualError(t, daemon.Start(context.Background()), "bad")
+		require.Equal(t, DaemonFailed, daemon.Status())
+	})
+	t.Run("stop_timeout", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		daemon := NewDaemon("test", func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+		go func() { _ = daemon.Start(context.Background()) }()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.EqualError(t, daemon.Stop(ctx), "daemon 'test' did not stop in time: context deadline exceeded")
+		require.Equal(t, DaemonStopping, daemon.Status())
+	})
+}
+
+func TestDaemonFromFunc(t *testing.T) {
+	t.Run("returns", func(t *testing.T) {
+		daemon := DaemonFromFunc("test", func() error { return errors.New("bad") })
+		require.Equal(t, "test", daemon.Name())
+		require.EqualError(t, daemon.Start(context.Background()), "bad")
+		require.Equal(t, DaemonFailed, daemon.Status())
+
+		daemon = DaemonFromFunc("test", func() error { return nil })
+		require.NoError(t, daemon.Start(context.Background()))
+		require.Equal(t, DaemonStopped, daemon.Status())
+	})
+	t.Run("panic", func(t *testing.T) {
+		daemon := DaemonFromFunc("test", func() error { panic("boom") })
+		require.EqualError(t, daemon.Start(context.Background()), "daemon panicked: boom")
+		require.Equal(t, DaemonFailed, daemon.Status())
+	})
+	t.Run("stop", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		daemon := DaemonFromFunc("test", func() error {
+			close(started)
+			<-release
+			return nil
+		})
+		result := make(chan error, 1)
+		go func() { result <- daemon.Start(context.Background()) }()
+		<-started
+		require.Equal(t, DaemonReady, daemon.Status())
+
+		// The function cannot be interrupted, but the daemon stops regardless
+		require.NoError(t, daemon.Stop(context.Background()))
+		require.NoError(t, <-result)
+		require.Equal(t, DaemonStopped, daemon.Status())
+	})
+}
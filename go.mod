@@ -5,10 +5,13 @@ go 1.12
 require (
 	github.com/go-chi/chi v4.0.2+incompatible
 	github.com/go-kit/kit v0.8.0
+	github.com/golang/protobuf v1.3.1
+	github.com/gorilla/websocket v1.4.2
 	github.com/kr/text v0.1.0
 	github.com/pkg/errors v0.8.1
 	github.com/prometheus/client_golang v0.9.3
 	github.com/rs/cors v1.6.0
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.2.2
+	google.golang.org/grpc v1.21.0
 )
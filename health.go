@@ -0,0 +1,191 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+const (
+	lifecycleIdle int32 = iota
+	lifecycleStarting
+	lifecycleRunning
+	lifecycleStopping
+)
+
+type HealthStatus string
+
+const (
+	HealthPass HealthStatus = "pass"
+	HealthWarn HealthStatus = "warn"
+	HealthFail HealthStatus = "fail"
+)
+
+// HealthCheck verifies that a dependency of the micro-service (such as a database) is available, returning an error if
+// it is not.
+type HealthCheck func(ctx context.Context) error
+
+type HealthCheckOption func(*healthCheckEntry)
+
+// Marks the check as non-critical; when it fails, the micro-service is reported as degraded, but still ready.
+func NonCritical() HealthCheckOption {
+	return func(entry *healthCheckEntry) {
+		entry.critical = false
+	}
+}
+
+// Overrides the default check timeout; checks that do not complete in time are considered failed.
+func WithHealthCheckTimeout(timeout time.Duration) HealthCheckOption {
+	return func(entry *healthCheckEntry) {
+		entry.timeout = timeout
+	}
+}
+
+type healthCheckEntry struct {
+	name     string
+	check    HealthCheck
+	critical bool
+	timeout  time.Duration
+}
+
+type HealthCheckResult struct {
+	Name     string       `json:"name"`
+	Status   HealthStatus `json:"status"`
+	Critical bool         `json:"critical"`
+	Error    string       `json:"error,omitempty"`
+	Duration string       `json:"duration"`
+}
+
+type DaemonHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type HealthReport struct {
+	Status  HealthStatus        `json:"status"`
+	Live    bool                `json:"live"`
+	Ready   bool                `json:"ready"`
+	Checks  []HealthCheckResult `json:"checks"`
+	Daemons []DaemonHealth      `json:"daemons"`
+}
+
+type healthRegistry struct {
+	mutex  sync.RWMutex
+	checks []*healthCheckEntry
+}
+
+// Registers a named health check. Checks are critical by default, meaning the micro-service is not ready while they
+// fail.
+func (ms *MicroService) AddHealthCheck(name string, check HealthCheck, options ...HealthCheckOption) {
+	entry := &healthCheckEntry{name: name, check: check, critical: true, timeout: DefaultHealthCheckTimeout}
+	for _, option := range options {
+		option(entry)
+	}
+
+	ms.health.mutex.Lock()
+	defer ms.health.mutex.Unlock()
+	ms.health.checks = append(ms.health.checks, entry)
+}
+
+// Reports whether the micro-service is alive, meaning none of its daemons has failed for good (ie. failed without being
+// restarted by its supervisor). Daemons waiting to be restarted are still considered alive. This does not run any
+// checks.
+func (ms *MicroService) Live() bool {
+	running, _ := ms.running.Load().([]*runningDaemon)
+	for _, rd := range running {
+		if atomic.LoadInt32(&rd.failed) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Runs all health checks concurrently, and reports their results along with the status of the micro-service daemons.
+// The micro-service is ready once all its daemons have started & are ready, all critical checks pass, and it is not
+// shutting down.
+func (ms *MicroService) CheckHealth(ctx context.Context) *HealthReport {
+	ms.health.mutex.RLock()
+	checks := append([]*healthCheckEntry{}, ms.health.checks...)
+	ms.health.mutex.RUnlock()
+
+	report := &HealthReport{
+		Live:    ms.Live(),
+		Ready:   ms.lifecycle() == lifecycleRunning,
+		Checks:  make([]HealthCheckResult, len(checks)),
+		Daemons: make([]DaemonHealth, 0, len(ms.daemons)),
+	}
+
+	// Run checks
+	wg := sync.WaitGroup{}
+	wg.Add(len(checks))
+	for i, c := range checks {
+		index, entry := i, c
+		go func() {
+			defer wg.Done()
+			report.Checks[index] = ms.runHealthCheck(ctx, entry)
+		}()
+	}
+	wg.Wait()
+
+	// Collect daemon statuses
+	for _, entry := range ms.daemons {
+		status := entry.daemon.Status()
+		if status != DaemonReady && status != DaemonStopped {
+			report.Ready = false
+		}
+		report.Daemons = append(report.Daemons, DaemonHealth{Name: entry.daemon.Name(), Status: status.String()})
+	}
+
+	// Summarize
+	report.Status = HealthPass
+	for _, result := range report.Checks {
+		if result.Status == HealthFail {
+			if result.Critical {
+				report.Ready = false
+			} else {
+				report.Status = HealthWarn
+			}
+		}
+	}
+	if !report.Live || !report.Ready {
+		report.Status = HealthFail
+	}
+	return report
+}
+
+func (ms *MicroService) runHealthCheck(ctx context.Context, entry *healthCheckEntry) (result HealthCheckResult) {
+	result = HealthCheckResult{Name: entry.name, Status: HealthPass, Critical: entry.critical}
+
+	ctx, cancel := context.WithTimeout(ctx, entry.timeout)
+	defer cancel()
+
+	// Run the check in a separate goroutine, so that checks ignoring the context's deadline do not block the report
+	start := time.Now()
+	checkResult := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				ms.Log("check", entry.name, "panic", rvr, "msg", "recovered from health check panic")
+				checkResult <- errors.Errorf("health check panicked: %v", rvr)
+			}
+		}()
+		checkResult <- entry.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-checkResult:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "health check did not complete in time")
+	}
+	result.Duration = time.Since(start).String()
+	if err != nil {
+		result.Status = HealthFail
+		result.Error = err.Error()
+	}
+	return result
+}
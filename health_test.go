@@ -0,0 +1,138 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCheckHealth(t *testing.T) {
+	t.Run("not_ready_before_running", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		report := ms.CheckHealth(context.Background())
+		require.Equal(t, HealthFail, report.Status)
+		require.True(t, report.Live)
+		require.False(t, report.Ready)
+	})
+	runAndCheck := func(t *testing.T, ms *MicroService) *HealthReport {
+		reports := make(chan *HealthReport, 1)
+		ms.AddDaemon(NewDaemon("checker", func(ctx context.Context) error {
+			for ms.lifecycle() != lifecycleRunning {
+				time.Sleep(5 * time.Millisecond)
+			}
+			reports <- ms.CheckHealth(ctx)
+			return nil
+		}))
+		require.NoError(t, ms.RunContext(context.Background()))
+		return <-reports
+	}
+	t.Run("pass", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddHealthCheck("db", func(ctx context.Context) error { return nil })
+		report := runAndCheck(t, ms)
+		require.Equal(t, HealthPass, report.Status)
+		require.True(t, report.Ready)
+		require.Len(t, report.Checks, 1)
+		require.Equal(t, "db", report.Checks[0].Name)
+		require.Equal(t, HealthPass, report.Checks[0].Status)
+		require.Equal(t, []DaemonHealth{{Name: "checker", Status: "ready"}}, report.Daemons)
+	})
+	t.Run("critical_failure", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddHealthCheck("db", func(ctx context.Context) error { return errors.New("down") })
+		report := runAndCheck(t, ms)
+		require.Equal(t, HealthFail, report.Status)
+		require.False(t, report.Ready)
+		require.True(t, report.Live)
+		require.Equal(t, "down", report.Checks[0].Error)
+	})
+	t.Run("non_critical_failure", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddHealthCheck("cache", func(ctx context.Context) error { return errors.New("down") }, NonCritical())
+		report := runAndCheck(t, ms)
+		require.Equal(t, HealthWarn, report.Status)
+		require.True(t, report.Ready)
+		require.False(t, report.Checks[0].Critical)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		release := make(chan struct{})
+		defer close(release)
+		ms.AddHealthCheck("stuck", func(ctx context.Context) error {
+			<-release
+			return nil
+		}, WithHealthCheckTimeout(20*time.Millisecond))
+		report := runAndCheck(t, ms)
+		require.Equal(t, HealthFail, report.Status)
+		require.Equal(t, "health check did not complete in time: context deadline exceeded", report.Checks[0].Error)
+	})
+	t.Run("panic", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddHealthCheck("bad", func(ctx context.Context) error { panic("boom") })
+		report := runAndCheck(t, ms)
+		require.Equal(t, HealthFail, report.Status)
+		require.Equal(t, "health check panicked: boom", report.Checks[0].Error)
+	})
+	t.Run("not_ready_while_shutting_down", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.SetShutdownDelay(50 * time.Millisecond)
+		reports := make(chan *HealthReport, 1)
+		ms.AddDaemon(NewDaemon("checker", func(ctx context.Context) error {
+			<-ctx.Done()
+			reports <- ms.CheckHealth(context.Background())
+			return nil
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		begin := time.Now()
+		require.NoError(t, ms.RunContext(ctx))
+		require.True(t, time.Since(begin) >= 70*time.Millisecond)
+		report := <-reports
+		require.False(t, report.Ready)
+		require.Equal(t, HealthFail, report.Status)
+	})
+	t.Run("not_live_when_daemon_failed", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("failing", func(ctx context.Context) error { return errors.New("bad") }))
+		require.Error(t, ms.RunContext(context.Background()))
+		require.False(t, ms.Live())
+	})
+	t.Run("live_while_restarting", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		failed := make(chan struct{})
+		ms.AddDaemon(NewDaemon("restarting", func(ctx context.Context) error {
+			close(failed)
+			return errors.New("bad")
+		}), WithRestartPolicy(RestartPolicy{Mode: RestartOnFailure, Backoff: time.Minute}))
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() { result <- ms.RunContext(ctx) }()
+
+		<-failed
+		time.Sleep(20 * time.Millisecond)
+		require.Equal(t, DaemonFailed, ms.Daemons()[0].Status())
+		require.True(t, ms.Live())
+		require.True(t, ms.CheckHealth(context.Background()).Live)
+		cancel()
+		require.Error(t, <-result)
+	})
+	t.Run("not_live_when_restarts_exhausted", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("failing", func(ctx context.Context) error { return errors.New("bad") }),
+			WithRestartPolicy(RestartPolicy{Mode: RestartOnFailure, Backoff: time.Millisecond, MaxRestarts: 1}))
+		require.Error(t, ms.RunContext(context.Background()))
+		require.False(t, ms.Live())
+	})
+}
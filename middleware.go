@@ -0,0 +1,140 @@
+package msvc
+
+import (
+	"github.com/pkg/errors"
+	"path"
+	"regexp"
+)
+
+type MiddlewareOption func(*middlewareEntry)
+
+// Names the middleware, allowing other middleware to be ordered relative to it, and allowing it to be removed.
+func Named(name string) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.name = name
+	}
+}
+
+// Applies the middleware only to the given methods.
+func ForMethods(names ...string) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.selectors = append(entry.selectors, func(descriptor *MethodDescriptor) bool {
+			for _, name := range names {
+				if name == descriptor.Name {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// Applies the middleware only to methods whose name matches the given glob pattern (eg. "Get*"); see path.Match for
+// the pattern syntax.
+func ForMethodsMatching(pattern string) MiddlewareOption {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(errors.Wrapf(err, "illegal method name pattern '%s'", pattern))
+	}
+	return func(entry *middlewareEntry) {
+		entry.selectors = append(entry.selectors, func(descriptor *MethodDescriptor) bool {
+			matched, _ := path.Match(pattern, descriptor.Name)
+			return matched
+		})
+	}
+}
+
+// Applies the middleware only to methods whose name matches the given regular expression.
+func ForMethodsMatchingRegexp(re *regexp.Regexp) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.selectors = append(entry.selectors, func(descriptor *MethodDescriptor) bool {
+			return re.MatchString(descriptor.Name)
+		})
+	}
+}
+
+// Applies the middleware only to methods carrying any of the given tags.
+func ForMethodsTagged(tags ...string) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.selectors = append(entry.selectors, func(descriptor *MethodDescriptor) bool {
+			for _, tag := range tags {
+				if descriptor.HasTag(tag) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// Places the middleware so that it runs before (ie. wraps) the named middleware, instead of running before all other
+// middleware.
+func Before(name string) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.before, entry.after = name, ""
+	}
+}
+
+// Places the middleware so that it runs after (ie. is wrapped by) the named middleware, instead of running before all
+// other middleware.
+func After(name string) MiddlewareOption {
+	return func(entry *middlewareEntry) {
+		entry.before, entry.after = "", name
+	}
+}
+
+type middlewareEntry struct {
+	name       string
+	middleware Middleware
+	selectors  []func(descriptor *MethodDescriptor) bool
+	before     string
+	after      string
+}
+
+// Whether the middleware applies to the given method; when scoped by multiple options, it applies to methods matching
+// any of them.
+func (e *middlewareEntry) appliesTo(descriptor *MethodDescriptor) bool {
+	if len(e.selectors) == 0 {
+		return true
+	}
+	for _, selector := range e.selectors {
+		if selector(descriptor) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inserts the given middleware into the given list, which is ordered from the innermost middleware to the outermost.
+// Fails (leaving the list unchanged) if the middleware's name is taken, or the middleware it is placed relative to is
+// not found.
+func insertMiddleware(middlewares []*middlewareEntry, entry *middlewareEntry) ([]*middlewareEntry, error) {
+	if entry.name != "" && indexOfMiddleware(middlewares, entry.name) >= 0 {
+		return middlewares, errors.Errorf("middleware '%s' already exists", entry.name)
+	}
+
+	index := len(middlewares)
+	if anchor := entry.before + entry.after; anchor != "" {
+		anchorIndex := indexOfMiddleware(middlewares, anchor)
+		if anchorIndex < 0 {
+			return middlewares, errors.Errorf("middleware '%s' not found", anchor)
+		} else if entry.before != "" {
+			index = anchorIndex + 1
+		} else {
+			index = anchorIndex
+		}
+	}
+
+	middlewares = append(middlewares, nil)
+	copy(middlewares[index+1:], middlewares[index:])
+	middlewares[index] = entry
+	return middlewares, nil
+}
+
+func indexOfMiddleware(middlewares []*middlewareEntry, name string) int {
+	for i, entry := range middlewares {
+		if entry.name == name {
+			return i
+		}
+	}
+	return -1
+}
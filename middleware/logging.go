@@ -5,10 +5,10 @@ import (
 	"github.com/arikkfir/msvc"
 )
 
-func Logging(ms *msvc.MicroService, methodName string, method msvc.Method) msvc.Method {
+func Logging(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
 	return func(ctx context.Context, request interface{}) (returnValue interface{}, err error) {
 		defer func() {
-			ms.Log("service", ms.Name(), "method", methodName, "request", request, "response", returnValue, "err", err)
+			ms.Log("service", ms.Name(), "method", descriptor.Name, "request", request, "response", returnValue, "err", err)
 		}()
 		return method(ctx, request)
 	}
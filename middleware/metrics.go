@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/arikkfir/msvc"
+	httpd "github.com/arikkfir/msvc/daemon/http"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
@@ -15,19 +16,12 @@ type MetricsConfig struct {
 }
 
 func NewMetricsServer(config *MetricsConfig) msvc.Daemon {
-	return func() error {
-		metricsMux := http.NewServeMux()
-		metricsMux.Handle("/metrics", promhttp.Handler())
-		metricsHttpServer := &http.Server{Addr: fmt.Sprintf(":%d", config.Port), Handler: metricsMux}
-		if err := metricsHttpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			return err
-		} else {
-			return nil
-		}
-	}
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	return httpd.NewServer("metrics", fmt.Sprintf(":%d", config.Port), metricsMux)
 }
 
-func MethodDuration(ms *msvc.MicroService, methodName string, method msvc.Method) msvc.Method {
+func MethodDuration(ms *msvc.MicroService, descriptor *msvc.MethodDescriptor, method msvc.Method) msvc.Method {
 	metric := prometheus.NewSummaryVec(prometheus.SummaryOpts{
 		Namespace: "services",
 		Subsystem: ms.Name(),
@@ -43,7 +37,7 @@ func MethodDuration(ms *msvc.MicroService, methodName string, method msvc.Method
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		defer func(begin time.Time) {
 			metric.
-				With(prometheus.Labels{"service": ms.Name(), "method": methodName}).
+				With(prometheus.Labels{"service": ms.Name(), "method": descriptor.Name}).
 				Observe(time.Since(begin).Seconds())
 		}(time.Now())
 		return method(ctx, request)
@@ -0,0 +1,103 @@
+package msvc
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestScopedMiddleware(t *testing.T) {
+	type Req struct{}
+	type Res struct{ Trace []string }
+	method := func(ctx context.Context, req *Req) (*Res, error) { return &Res{}, nil }
+	tracing := func(s string) Middleware {
+		return func(ms *MicroService, descriptor *MethodDescriptor, method Method) Method {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				response, err := method(ctx, request)
+				response.(*Res).Trace = append(response.(*Res).Trace, s)
+				return response, err
+			}
+		}
+	}
+	// Returns the middleware applied to the given method, from outermost to innermost
+	traceOf := func(t *testing.T, ms *MicroService, name string) string {
+		response, err := ms.GetMethod(name)(context.Background(), Req{})
+		require.NoError(t, err)
+		trace := response.(*Res).Trace
+		for i, j := 0, len(trace)-1; i < j; i, j = i+1, j-1 {
+			trace[i], trace[j] = trace[j], trace[i]
+		}
+		return strings.Join(trace, ",")
+	}
+	newService := func(t *testing.T) *MicroService {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("GetUser", method, WithTags("users", "read"))
+		ms.AddMethod("ListUsers", method, WithTags("users", "read"))
+		ms.AddMethod("CreateUser", method, WithTags("users", "write"))
+		ms.AddMethod("GetOrder", method)
+		return ms
+	}
+
+	t.Run("for_methods", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("m"), ForMethods("GetUser", "GetOrder"))
+		require.Equal(t, "m", traceOf(t, ms, "GetUser"))
+		require.Equal(t, "", traceOf(t, ms, "ListUsers"))
+		require.Equal(t, "m", traceOf(t, ms, "GetOrder"))
+	})
+	t.Run("for_methods_matching", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("m"), ForMethodsMatching("Get*"))
+		require.Equal(t, "m", traceOf(t, ms, "GetUser"))
+		require.Equal(t, "", traceOf(t, ms, "CreateUser"))
+		require.Equal(t, "m", traceOf(t, ms, "GetOrder"))
+		require.Panics(t, func() { ForMethodsMatching("[") })
+	})
+	t.Run("for_methods_matching_regexp", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("m"), ForMethodsMatchingRegexp(regexp.MustCompile("Users?$")))
+		require.Equal(t, "m", traceOf(t, ms, "GetUser"))
+		require.Equal(t, "m", traceOf(t, ms, "ListUsers"))
+		require.Equal(t, "", traceOf(t, ms, "GetOrder"))
+	})
+	t.Run("for_methods_tagged", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("m"), ForMethodsTagged("write"))
+		require.Equal(t, "", traceOf(t, ms, "GetUser"))
+		require.Equal(t, "m", traceOf(t, ms, "CreateUser"))
+		require.Equal(t, "", traceOf(t, ms, "GetOrder"))
+	})
+	t.Run("multiple_scopes", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("m"), ForMethodsTagged("write"), ForMethods("GetOrder"))
+		require.Equal(t, "", traceOf(t, ms, "GetUser"))
+		require.Equal(t, "m", traceOf(t, ms, "CreateUser"))
+		require.Equal(t, "m", traceOf(t, ms, "GetOrder"))
+	})
+	t.Run("ordering", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("a"), Named("a"))
+		ms.AddMiddleware(tracing("b"), Named("b"))
+		require.Equal(t, "b,a", traceOf(t, ms, "GetOrder"))
+		ms.AddMiddleware(tracing("c"), Named("c"), Before("a"))
+		require.Equal(t, "b,c,a", traceOf(t, ms, "GetOrder"))
+		ms.AddMiddleware(tracing("d"), Named("d"), After("a"))
+		require.Equal(t, "b,c,a,d", traceOf(t, ms, "GetOrder"))
+		ms.AddMiddleware(tracing("e"), After("b"))
+		require.Equal(t, "b,e,c,a,d", traceOf(t, ms, "GetOrder"))
+		require.EqualError(t, ms.AddMiddleware(tracing("x"), Before("unknown")), "middleware 'unknown' not found")
+		require.EqualError(t, ms.AddMiddleware(tracing("x"), Named("a")), "middleware 'a' already exists")
+		require.Equal(t, "b,e,c,a,d", traceOf(t, ms, "GetOrder"))
+	})
+	t.Run("remove", func(t *testing.T) {
+		ms := newService(t)
+		ms.AddMiddleware(tracing("a"), Named("a"))
+		ms.AddMiddleware(tracing("b"), Named("b"))
+		require.True(t, ms.RemoveMiddleware("a"))
+		require.False(t, ms.RemoveMiddleware("a"))
+		require.Equal(t, "b", traceOf(t, ms, "GetOrder"))
+	})
+}
@@ -0,0 +1,123 @@
+package msvc
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Describes a registered method. Descriptors are created when methods are added, and must not be modified afterwards.
+type MethodDescriptor struct {
+	Name        string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Idempotent  bool
+	Metadata    map[string]string
+	Adapter     MethodAdapter
+}
+
+// Whether the method carries the given tag.
+func (d *MethodDescriptor) HasTag(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+type MethodOption func(*MethodDescriptor)
+
+func WithDescription(description string) MethodOption {
+	return func(descriptor *MethodDescriptor) {
+		descriptor.Description = description
+	}
+}
+
+// Tags the method, allowing middleware to be applied to methods by tag.
+func WithTags(tags ...string) MethodOption {
+	return func(descriptor *MethodDescriptor) {
+		descriptor.Tags = append(descriptor.Tags, tags...)
+	}
+}
+
+func Deprecated() MethodOption {
+	return func(descriptor *MethodDescriptor) {
+		descriptor.Deprecated = true
+	}
+}
+
+// Marks the method as idempotent, meaning invoking it multiple times has the same effect as invoking it once.
+func Idempotent() MethodOption {
+	return func(descriptor *MethodDescriptor) {
+		descriptor.Idempotent = true
+	}
+}
+
+// Adds custom metadata to the method, for use by middleware & tooling.
+func WithMetadata(key, value string) MethodOption {
+	return func(descriptor *MethodDescriptor) {
+		descriptor.Metadata[key] = value
+	}
+}
+
+// An immutable view of the registered methods & middleware, along with the method chains compiled from them.
+type methodSnapshot struct {
+	methods      map[string]*MethodDescriptor
+	middlewares  []*middlewareEntry
+	methodChains map[string]Method
+}
+
+// A registry of methods & middleware, safe for concurrent use. Readers access the current snapshot without locking,
+// while writers (serialized by a mutex) build & compile a new snapshot, and then atomically swap it in.
+type methodRegistry struct {
+	mutex    sync.Mutex
+	snapshot atomic.Value
+}
+
+func (r *methodRegistry) current() *methodSnapshot {
+	if snapshot, ok := r.snapshot.Load().(*methodSnapshot); ok {
+		return snapshot
+	}
+	return &methodSnapshot{}
+}
+
+// Applies the given mutation to a copy of the current snapshot, compiles its method chains, and swaps it in.
+func (r *methodRegistry) update(ms *MicroService, mutate func(snapshot *methodSnapshot)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current := r.current()
+	next := &methodSnapshot{
+		methods:     make(map[string]*MethodDescriptor, len(current.methods)),
+		middlewares: append(make([]*middlewareEntry, 0, len(current.middlewares)), current.middlewares...),
+	}
+	for name, method := range current.methods {
+		next.methods[name] = method
+	}
+	mutate(next)
+
+	next.methodChains = make(map[string]Method, len(next.methods))
+	for name, descriptor := range next.methods {
+		currentMethod := descriptor.Adapter.Call
+		for _, mw := range next.middlewares {
+			if mw.appliesTo(descriptor) {
+				currentMethod = mw.middleware(ms, descriptor, currentMethod)
+			}
+		}
+		next.methodChains[name] = currentMethod
+	}
+	r.snapshot.Store(next)
+}
+
+// Returns the descriptors of all registered methods, sorted by name.
+func (r *methodRegistry) descriptors() []*MethodDescriptor {
+	methods := r.current().methods
+	descriptors := make([]*MethodDescriptor, 0, len(methods))
+	for _, descriptor := range methods {
+		descriptors = append(descriptors, descriptor)
+	}
+	sort.Slice(descriptors, func(i, j int) bool { return descriptors[i].Name < descriptors[j].Name })
+	return descriptors
+}
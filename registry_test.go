@@ -0,0 +1,130 @@
+package msvc
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestMethodRegistry(t *testing.T) {
+	type Req struct{ P string }
+	type Res struct{ P string }
+	echo := func(ctx context.Context, req *Req) (*Res, error) { return &Res{P: req.P}, nil }
+	suffix := func(s string) Middleware {
+		return func(ms *MicroService, descriptor *MethodDescriptor, method Method) Method {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				response, err := method(ctx, request)
+				if err != nil {
+					return nil, err
+				}
+				return &Res{P: response.(*Res).P + s}, nil
+			}
+		}
+	}
+
+	t.Run("add_get_remove", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		require.Nil(t, ms.GetMethod("Echo"))
+		require.Nil(t, ms.GetMethodAdapter("Echo"))
+
+		adapter := ms.AddMethod("Echo", echo)
+		require.Equal(t, adapter, ms.GetMethodAdapter("Echo"))
+		response, err := ms.GetMethod("Echo")(context.Background(), Req{P: "v"})
+		require.NoError(t, err)
+		require.Equal(t, &Res{P: "v"}, response)
+
+		ms.RemoveMethod("Echo")
+		require.Nil(t, ms.GetMethod("Echo"))
+		require.Nil(t, ms.GetMethodAdapter("Echo"))
+	})
+	t.Run("middleware_applies_to_existing_and_new_methods", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("Before", echo)
+		ms.AddMiddleware(suffix("-a"))
+		ms.AddMiddleware(suffix("-b"))
+		ms.AddMethod("After", echo)
+		for _, name := range []string{"Before", "After"} {
+			response, err := ms.GetMethod(name)(context.Background(), Req{P: "v"})
+			require.NoError(t, err)
+			require.Equal(t, &Res{P: "v-a-b"}, response)
+		}
+	})
+	t.Run("concurrent_use", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("Echo", echo)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					response, err := ms.GetMethod("Echo")(context.Background(), Req{P: "v"})
+					require.NoError(t, err)
+					require.Contains(t, response.(*Res).P, "v")
+					require.NotNil(t, ms.GetMethodAdapter("Echo"))
+				}
+			}()
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					name := fmt.Sprintf("Method%d_%d", i, j)
+					ms.AddMethod(name, echo)
+					ms.AddMiddleware(suffix(""))
+					ms.RemoveMethod(name)
+				}
+			}(i)
+		}
+		wg.Wait()
+		require.Nil(t, ms.GetMethod("Method0_0"))
+	})
+	t.Run("descriptors", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("B", echo)
+		adapter := ms.AddMethod("A", echo,
+			WithDescription("Echoes the request"),
+			WithTags("t1"),
+			WithTags("t2"),
+			Deprecated(),
+			Idempotent(),
+			WithMetadata("k", "v"))
+
+		descriptor := ms.GetMethodDescriptor("A")
+		require.Equal(t, &MethodDescriptor{
+			Name:        "A",
+			Description: "Echoes the request",
+			Tags:        []string{"t1", "t2"},
+			Deprecated:  true,
+			Idempotent:  true,
+			Metadata:    map[string]string{"k": "v"},
+			Adapter:     adapter,
+		}, descriptor)
+		require.True(t, descriptor.HasTag("t2"))
+		require.False(t, descriptor.HasTag("t3"))
+		require.Nil(t, ms.GetMethodDescriptor("C"))
+
+		methods := ms.Methods()
+		require.Len(t, methods, 2)
+		require.Equal(t, "A", methods[0].Name)
+		require.Equal(t, "B", methods[1].Name)
+		require.False(t, methods[1].Deprecated)
+		require.Empty(t, methods[1].Metadata)
+	})
+	t.Run("middleware_receives_descriptor", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddMethod("Echo", echo, WithTags("t"))
+		received := make(map[string]*MethodDescriptor)
+		ms.AddMiddleware(func(ms *MicroService, descriptor *MethodDescriptor, method Method) Method {
+			received[descriptor.Name] = descriptor
+			return method
+		})
+		require.Equal(t, ms.GetMethodDescriptor("Echo"), received["Echo"])
+	})
+}
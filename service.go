@@ -7,13 +7,15 @@ import (
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 	stdlog "log"
 	"os"
 	"os/signal"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 const (
@@ -22,6 +24,11 @@ const (
 	contextMsKey = "__ms"
 )
 
+const (
+	DefaultStartupTimeout  = 30 * time.Second
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
 func GetFromContext(ctx context.Context) *MicroService {
 	value := ctx.Value(contextMsKey)
 	if value == nil {
@@ -37,19 +44,22 @@ func SetInContext(ctx context.Context, ms *MicroService) context.Context {
 
 type Method func(ctx context.Context, request interface{}) (interface{}, error)
 
-type Middleware func(ms *MicroService, methodName string, method Method) Method
-
-type Daemon func() error
+type Middleware func(ms *MicroService, descriptor *MethodDescriptor, method Method) Method
 
 type MicroService struct {
-	config       interface{}
-	environment  int
-	log          kitlog.Logger
-	methods      map[string]MethodAdapter
-	daemons      []Daemon
-	middlewares  []Middleware
-	methodChains map[string]Method
-	name         string
+	config          interface{}
+	environment     int
+	log             kitlog.Logger
+	daemons         []*daemonEntry
+	daemonRestarts  *prometheus.CounterVec
+	running         atomic.Value // daemons of the current (or last) run, as []*runningDaemon
+	registry        methodRegistry
+	name            string
+	startupTimeout  time.Duration
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+	state           int32
+	health          healthRegistry
 }
 
 func New(name string, config interface{}) (*MicroService, error) {
@@ -64,7 +74,35 @@ func New(name string, config interface{}) (*MicroService, error) {
 		envName = "prod"
 	}
 
-	// Log level
+	// Determine how long daemons are given to become ready when starting up
+	startupTimeout := DefaultStartupTimeout
+	if value := os.Getenv(prefix + "_STARTUP_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err != nil {
+			return nil, errors.Wrapf(err, "illegal startup timeout: %s", value)
+		} else {
+			startupTimeout = d
+		}
+	}
+
+	// Determine how long to wait after readiness starts failing, before stopping daemons
+	var shutdownDelay time.Duration
+	if value := os.Getenv(prefix + "_SHUTDOWN_DELAY"); value != "" {
+		if d, err := time.ParseDuration(value); err != nil {
+			return nil, errors.Wrapf(err, "illegal shutdown delay: %s", value)
+		} else {
+			shutdownDelay = d
+		}
+	}
+
+	// Determine how long daemons are given to drain when shutting down
+	shutdownTimeout := DefaultShutdownTimeout
+	if value := os.Getenv(prefix + "_SHUTDOWN_TIMEOUT"); value != "" {
+		if d, err := time.ParseDuration(value); err != nil {
+			return nil, errors.Wrapf(err, "illegal shutdown timeout: %s", value)
+		} else {
+			shutdownTimeout = d
+		}
+	}
 
 	// Configure stdout
 	var logger kitlog.Logger
@@ -104,16 +142,22 @@ func New(name string, config interface{}) (*MicroService, error) {
 		return nil, errors.Wrap(err, "failed reading configuration")
 	}
 
+	daemonRestarts, err := newDaemonRestartsMetric()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the service
 	return &MicroService{
-		config:       config,
-		environment:  environment,
-		log:          logger,
-		name:         name,
-		daemons:      make([]Daemon, 0),
-		middlewares:  make([]Middleware, 0),
-		methods:      make(map[string]MethodAdapter, 0),
-		methodChains: make(map[string]Method, 0),
+		config:          config,
+		environment:     environment,
+		log:             logger,
+		name:            name,
+		daemons:         make([]*daemonEntry, 0),
+		daemonRestarts:  daemonRestarts,
+		startupTimeout:  startupTimeout,
+		shutdownDelay:   shutdownDelay,
+		shutdownTimeout: shutdownTimeout,
 	}, nil
 }
 
@@ -133,86 +177,253 @@ func (ms *MicroService) Name() string {
 	return ms.name
 }
 
-func (ms *MicroService) AddMethod(name string, method interface{}) MethodAdapter {
-	adapter := NewAdapter(method)
-	ms.methods[name] = adapter
-	ms.compileMethodChains()
-	return adapter
+func (ms *MicroService) lifecycle() int32 {
+	return atomic.LoadInt32(&ms.state)
+}
+
+func (ms *MicroService) StartupTimeout() time.Duration {
+	return ms.startupTimeout
+}
+
+func (ms *MicroService) SetStartupTimeout(timeout time.Duration) {
+	ms.startupTimeout = timeout
+}
+
+func (ms *MicroService) ShutdownDelay() time.Duration {
+	return ms.shutdownDelay
+}
+
+// Sets how long to wait between starting to report the micro-service as not ready, and stopping its daemons; this gives
+// load balancers a chance to stop sending new traffic to the micro-service before it drains.
+func (ms *MicroService) SetShutdownDelay(delay time.Duration) {
+	ms.shutdownDelay = delay
+}
+
+func (ms *MicroService) ShutdownTimeout() time.Duration {
+	return ms.shutdownTimeout
+}
+
+func (ms *MicroService) SetShutdownTimeout(timeout time.Duration) {
+	ms.shutdownTimeout = timeout
+}
+
+func (ms *MicroService) AddMethod(name string, method interface{}, options ...MethodOption) MethodAdapter {
+	descriptor := &MethodDescriptor{Name: name, Metadata: make(map[string]string), Adapter: NewAdapter(method)}
+	for _, option := range options {
+		option(descriptor)
+	}
+	ms.registry.update(ms, func(snapshot *methodSnapshot) {
+		snapshot.methods[name] = descriptor
+	})
+	return descriptor.Adapter
 }
 
 func (ms *MicroService) GetMethod(name string) Method {
-	return ms.methodChains[name]
+	return ms.registry.current().methodChains[name]
 }
 
 func (ms *MicroService) GetMethodAdapter(name string) MethodAdapter {
-	return ms.methods[name]
+	if descriptor, ok := ms.registry.current().methods[name]; ok {
+		return descriptor.Adapter
+	}
+	return nil
 }
 
-func (ms *MicroService) RemoveMethod(name string) {
-	delete(ms.methods, name)
-	ms.compileMethodChains()
+func (ms *MicroService) GetMethodDescriptor(name string) *MethodDescriptor {
+	return ms.registry.current().methods[name]
 }
 
-func (ms *MicroService) AddMiddleware(middleware Middleware) {
-	ms.middlewares = append(ms.middlewares, middleware)
-	ms.compileMethodChains()
+// Returns the descriptors of all registered methods, sorted by name.
+func (ms *MicroService) Methods() []*MethodDescriptor {
+	return ms.registry.descriptors()
 }
 
-func (ms *MicroService) AddDaemon(daemon Daemon) {
-	ms.daemons = append(ms.daemons, daemon)
+func (ms *MicroService) RemoveMethod(name string) {
+	ms.registry.update(ms, func(snapshot *methodSnapshot) {
+		delete(snapshot.methods, name)
+	})
 }
 
-func (ms *MicroService) compileMethodChains() {
-	methodChains := map[string]Method{}
-	for name, methodAdapter := range ms.methods {
-		currentMethod := methodAdapter.Call
-		for _, mw := range ms.middlewares {
-			currentMethod = mw(ms, name, currentMethod)
+// Adds the given middleware, by default to all methods, and before all other middleware (ie. wrapping them). Options
+// can restrict the methods it applies to, name it, and place it relative to other named middleware. Fails, without
+// adding the middleware, if its name is already taken or the middleware it is placed relative to is not found.
+func (ms *MicroService) AddMiddleware(middleware Middleware, options ...MiddlewareOption) error {
+	entry := &middlewareEntry{middleware: middleware}
+	for _, option := range options {
+		option(entry)
+	}
+	var err error
+	ms.registry.update(ms, func(snapshot *methodSnapshot) {
+		snapshot.middlewares, err = insertMiddleware(snapshot.middlewares, entry)
+	})
+	return err
+}
+
+// Removes the named middleware, returning whether it was found.
+func (ms *MicroService) RemoveMiddleware(name string) bool {
+	found := false
+	ms.registry.update(ms, func(snapshot *methodSnapshot) {
+		if index := indexOfMiddleware(snapshot.middlewares, name); index >= 0 {
+			snapshot.middlewares = append(snapshot.middlewares[:index], snapshot.middlewares[index+1:]...)
+			found = true
 		}
-		methodChains[name] = currentMethod
+	})
+	return found
+}
+
+func (ms *MicroService) AddDaemon(daemon Daemon, options ...DaemonOption) {
+	entry := &daemonEntry{daemon: daemon}
+	for _, option := range options {
+		option(entry)
 	}
-	ms.methodChains = methodChains
+	ms.daemons = append(ms.daemons, entry)
 }
 
-func (ms *MicroService) Run() {
+func (ms *MicroService) Daemons() []Daemon {
+	daemons := make([]Daemon, 0, len(ms.daemons))
+	for _, entry := range ms.daemons {
+		daemons = append(daemons, entry.daemon)
+	}
+	return daemons
+}
+
+// Runs the micro-service until the given context is cancelled, all daemons exit, or a daemon fails (after exhausting its
+// restart policy). Daemons are started one at a time in dependency order, each becoming ready before the next one is
+// started. When stopping (in the first & last cases) remaining daemons are stopped in reverse order, and are given up to
+// the shutdown timeout to do so. Returns the first daemon error, or nil if all daemons stopped cleanly.
+func (ms *MicroService) RunContext(ctx context.Context) error {
+	entries, err := ms.orderDaemons()
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&ms.state, lifecycleStarting)
+	defer atomic.StoreInt32(&ms.state, lifecycleIdle)
+
+	// Daemons receive a context that carries the values of the given context, but is only cancelled separately for each
+	// daemon, when it is its turn to stop
+	daemonsCtx := SetInContext(detachedContext{ctx}, ms)
+	exited := make(chan *runningDaemon, len(entries))
+	started := make([]*runningDaemon, 0, len(entries))
+	running := 0
+
+	// Start daemons in order, and wait until all daemons exit, a daemon fails, or the context is cancelled
+	var result error
+	var pending *runningDaemon
+	var pendingDeadline time.Time
+	readinessTicker := time.NewTicker(10 * time.Millisecond)
+	defer readinessTicker.Stop()
+	for result == nil && ctx.Err() == nil && (running > 0 || len(started) < len(entries)) {
+		if pending == nil && len(started) < len(entries) {
+			pending = ms.launchDaemon(daemonsCtx, entries[len(started)], exited)
+			pendingDeadline = time.Now().Add(ms.startupTimeout)
+			started = append(started, pending)
+			ms.running.Store(append([]*runningDaemon{}, started...))
+			running++
+		} else if pending == nil {
+			atomic.CompareAndSwapInt32(&ms.state, lifecycleStarting, lifecycleRunning)
+		}
+
+		var readinessCheck <-chan time.Time
+		if pending != nil {
+			readinessCheck = readinessTicker.C
+		}
+		select {
+		case rd := <-exited:
+			running--
+			rd.exited = true
+			if rd.err != nil {
+				result = errors.Wrapf(rd.err, "daemon '%s' failed", rd.entry.daemon.Name())
+			}
+			if rd == pending {
+				pending = nil
+			}
+		case <-readinessCheck:
+			if pending.entry.daemon.Status() == DaemonReady {
+				pending = nil
+			} else if time.Now().After(pendingDeadline) {
+				result = errors.Errorf("daemon '%s' did not become ready within %s", pending.entry.daemon.Name(), ms.startupTimeout)
+			}
+		case <-ctx.Done():
+		}
+	}
 
-	// Start each daemon in a goroutine; if a daemon fails, it will send the error to the errors channel (termination)
-	daemonsWaitGroup := sync.WaitGroup{}
-	daemonsWaitGroup.Add(len(ms.daemons))
-	errChan := make(chan error, 1)
-	doneChan := make(chan bool, 1)
-	for _, d := range ms.daemons {
-		daemon := d
-		go func() {
-			if err := daemon(); err != nil {
-				errChan <- errors.Wrapf(err, "daemon failed")
-			} else {
-				daemonsWaitGroup.Done()
+	// Stop remaining daemons in reverse order, waiting for each to drain & exit before stopping the next, but no longer
+	// than the shutdown timeout; before that, report the micro-service as not ready, and give load balancers time to
+	// notice it
+	atomic.StoreInt32(&ms.state, lifecycleStopping)
+	if running > 0 {
+		if ms.shutdownDelay > 0 {
+			ms.Log("msg", "shutting down in "+ms.shutdownDelay.String())
+			time.Sleep(ms.shutdownDelay)
+		}
+		stopCtx, stopCancel := context.WithTimeout(SetInContext(context.Background(), ms), ms.shutdownTimeout)
+		defer stopCancel()
+		for i := len(started) - 1; i >= 0; i-- {
+			rd := started[i]
+			if rd.exited {
+				continue
 			}
-		}()
+			rd.cancel() // prevents the supervisor from restarting the daemon
+			if err := rd.entry.daemon.Stop(stopCtx); err != nil {
+				ms.Log("daemon", rd.entry.daemon.Name(), "err", errors.Wrap(err, "failed stopping daemon"))
+			}
+			for !rd.exited {
+				select {
+				case other := <-exited:
+					other.exited = true
+					if other.err != nil {
+						err := errors.Wrapf(other.err, "daemon '%s' failed during shutdown", other.entry.daemon.Name())
+						if result == nil {
+							result = err
+						} else {
+							ms.Log("err", err)
+						}
+					}
+				case <-stopCtx.Done():
+					remaining := 0
+					for _, rd := range started {
+						if !rd.exited {
+							remaining++
+						}
+					}
+					err := errors.Errorf("%d daemon(s) did not stop within %s", remaining, ms.shutdownTimeout)
+					if result == nil {
+						return err
+					}
+					ms.Log("err", err)
+					return result
+				}
+			}
+		}
 	}
+	return result
+}
 
-	// Start a goroutine which will wait until all daemons exit successfully, and if so notify the "done" channel
-	go func() {
-		daemonsWaitGroup.Wait()
-		doneChan <- true
-	}()
+// Runs the micro-service until it receives SIGINT or SIGTERM, or until its daemons exit, and then exits the process
+// with a status code reflecting whether the micro-service stopped cleanly. A second signal received while shutting
+// down exits the process immediately.
+func (ms *MicroService) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Listen for OS signals SIGINT and SIGTERM
-	signalsChan := make(chan os.Signal, 1)
+	signalsChan := make(chan os.Signal, 2)
 	signal.Notify(signalsChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalsChan)
+	go func() {
+		sig := <-signalsChan
+		ms.Log("msg", "received signal '"+sig.String()+"', shutting down")
+		cancel()
 
-	//
-	// Wait until we get an error or a signal, print it and exit
-	select {
-	case err := <-errChan:
-		ms.Log("err", err)
+		sig = <-signalsChan
+		ms.Log("msg", "received signal '"+sig.String()+"' during shutdown, exiting immediately")
 		os.Exit(1)
-	case sig := <-signalsChan:
-		ms.Log("msg", "received signal '"+sig.String()+"'")
+	}()
+
+	if err := ms.RunContext(ctx); err != nil {
+		ms.Log("err", err)
 		os.Exit(1)
-	case <-doneChan:
-		ms.Log("msg", "done")
-		os.Exit(0)
 	}
+	ms.Log("msg", "done")
+	os.Exit(0)
 }
@@ -0,0 +1,84 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRunContext(t *testing.T) {
+	blockingDaemon := NewDaemon("blocking", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	t.Run("no_daemons", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		require.NoError(t, ms.RunContext(context.Background()))
+	})
+	t.Run("daemons_exit", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("d1", func(ctx context.Context) error { return nil }))
+		ms.AddDaemon(NewDaemon("d2", func(ctx context.Context) error { return nil }))
+		require.NoError(t, ms.RunContext(context.Background()))
+	})
+	t.Run("context_cancelled", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(blockingDaemon)
+		ms.AddDaemon(NewDaemon("blocking2", func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		require.NoError(t, ms.RunContext(ctx))
+	})
+	t.Run("daemon_fails", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(blockingDaemon)
+		ms.AddDaemon(NewDaemon("failing", func(ctx context.Context) error { return errors.New("bad") }))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'failing' failed: bad")
+		require.Equal(t, DaemonStopped, blockingDaemon.Status())
+	})
+	t.Run("daemon_fails_during_shutdown", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("failing", func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("bad")
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		require.EqualError(t, ms.RunContext(ctx), "daemon 'failing' failed during shutdown: bad")
+	})
+	t.Run("shutdown_timeout", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.SetShutdownTimeout(50 * time.Millisecond)
+		release := make(chan struct{})
+		defer close(release)
+		ms.AddDaemon(NewDaemon("stuck", func(ctx context.Context) error {
+			<-release
+			return nil
+		}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		require.EqualError(t, ms.RunContext(ctx), "1 daemon(s) did not stop within 50ms")
+	})
+	t.Run("context_provides_micro_service", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		var found *MicroService
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			found = GetFromContext(ctx)
+			return nil
+		}))
+		require.NoError(t, ms.RunContext(context.Background()))
+		require.Equal(t, ms, found)
+	})
+}
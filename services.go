@@ -0,0 +1,128 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// Derives the registered name of a service method, from the name of the service struct type & the method name.
+type NamingConvention func(serviceName, methodName string) string
+
+// Registers service methods under their method name (eg. "GetUsers").
+func MethodNameConvention(serviceName, methodName string) string {
+	return methodName
+}
+
+// Registers service methods under their service & method names (eg. "UsersService.GetUsers").
+func QualifiedNameConvention(serviceName, methodName string) string {
+	return serviceName + "." + methodName
+}
+
+type ServiceOption func(*serviceOptions)
+
+type serviceOptions struct {
+	namingConvention NamingConvention
+	strict           bool
+	methodOptions    []MethodOption
+}
+
+// Sets the naming convention used to derive method names; defaults to MethodNameConvention.
+func WithNamingConvention(convention NamingConvention) ServiceOption {
+	return func(options *serviceOptions) {
+		options.namingConvention = convention
+	}
+}
+
+// Fails the registration if any method looks like a service method (ie. its first parameter is a context.Context) but
+// does not match the expected signature. By default, such methods are only logged & skipped.
+func StrictSignatures() ServiceOption {
+	return func(options *serviceOptions) {
+		options.strict = true
+	}
+}
+
+// Applies the given method options to every method registered from the service.
+func WithMethodOptions(methodOptions ...MethodOption) ServiceOption {
+	return func(options *serviceOptions) {
+		options.methodOptions = append(options.methodOptions, methodOptions...)
+	}
+}
+
+// Registers every exported method of the given service (usually a pointer to a struct) matching the service method
+// signature (see NewAdapter). Returns the descriptors of the registered methods, or an error (registering no methods)
+// if any derived method name is already registered.
+func (ms *MicroService) AddService(service interface{}, options ...ServiceOption) ([]*MethodDescriptor, error) {
+	opts := &serviceOptions{namingConvention: MethodNameConvention}
+	for _, option := range options {
+		option(opts)
+	}
+
+	if service == nil {
+		return nil, errors.Errorf("nil service provided")
+	}
+	serviceValue := reflect.ValueOf(service)
+	serviceType := serviceValue.Type()
+	serviceName := serviceType.Name()
+	if serviceType.Kind() == reflect.Ptr {
+		serviceName = serviceType.Elem().Name()
+	}
+
+	// Collect matching methods, and the methods that look like service methods but have the wrong signature
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	descriptors := make([]*MethodDescriptor, 0)
+	mismatches := make([]string, 0)
+	for i := 0; i < serviceType.NumMethod(); i++ {
+		method := serviceType.Method(i)
+		methodValue := serviceValue.Method(i)
+		methodType := methodValue.Type()
+		if methodType.NumIn() == 0 || !methodType.In(0).Implements(contextType) {
+			continue
+		} else if err := validateMethodType(methodType); err != nil && streamingKindOf(methodType) == notStreaming {
+			mismatches = append(mismatches, method.Name+": "+err.Error())
+			continue
+		}
+
+		descriptor := &MethodDescriptor{
+			Name:     opts.namingConvention(serviceName, method.Name),
+			Metadata: make(map[string]string),
+			Adapter:  NewAdapter(methodValue.Interface()),
+		}
+		for _, option := range opts.methodOptions {
+			option(descriptor)
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+
+	if len(mismatches) > 0 {
+		if opts.strict {
+			return nil, errors.Errorf("service '%s' has methods with wrong signatures:\n%s", serviceName, strings.Join(mismatches, "\n"))
+		}
+		for _, mismatch := range mismatches {
+			ms.Log("service", serviceName, "msg", "skipping method with wrong signature: "+mismatch)
+		}
+	}
+
+	// Register all methods, unless any of them conflicts with a registered method (or with another method of the service)
+	conflicts := make([]string, 0)
+	ms.registry.update(ms, func(snapshot *methodSnapshot) {
+		names := make(map[string]bool, len(descriptors))
+		for _, descriptor := range descriptors {
+			if _, ok := snapshot.methods[descriptor.Name]; ok || names[descriptor.Name] {
+				conflicts = append(conflicts, descriptor.Name)
+			}
+			names[descriptor.Name] = true
+		}
+		if len(conflicts) > 0 {
+			return
+		}
+		for _, descriptor := range descriptors {
+			snapshot.methods[descriptor.Name] = descriptor
+		}
+	})
+	if len(conflicts) > 0 {
+		return nil, errors.Errorf("service '%s' has methods conflicting with registered methods: %s", serviceName, strings.Join(conflicts, ", "))
+	}
+	return descriptors, nil
+}
@@ -0,0 +1,100 @@
+package msvc
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testUsersRequest struct{ Name string }
+type testUsersResponse struct{ Names []string }
+
+type testUsersService struct{ prefix string }
+
+func (s *testUsersService) GetUsers(ctx context.Context, r *testUsersRequest) (*testUsersResponse, error) {
+	return &testUsersResponse{Names: []string{s.prefix + r.Name}}, nil
+}
+
+func (s *testUsersService) CreateUser(ctx context.Context, r *testUsersRequest) (*testUsersResponse, error) {
+	return &testUsersResponse{}, nil
+}
+
+// Looks like a service method, but has the wrong signature
+func (s *testUsersService) DeleteUser(ctx context.Context, name string) error {
+	return nil
+}
+
+// Does not look like a service method
+func (s *testUsersService) String() string {
+	return "users"
+}
+
+func TestAddService(t *testing.T) {
+	t.Run("registers_matching_methods", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		descriptors, err := ms.AddService(&testUsersService{prefix: "user-"})
+		require.NoError(t, err)
+		require.Len(t, descriptors, 2)
+		require.Equal(t, "CreateUser", descriptors[0].Name)
+		require.Equal(t, "GetUsers", descriptors[1].Name)
+
+		names := make([]string, 0)
+		for _, descriptor := range ms.Methods() {
+			names = append(names, descriptor.Name)
+		}
+		require.Equal(t, []string{"CreateUser", "GetUsers"}, names)
+
+		response, err := ms.GetMethod("GetUsers")(context.Background(), testUsersRequest{Name: "joe"})
+		require.NoError(t, err)
+		require.Equal(t, &testUsersResponse{Names: []string{"user-joe"}}, response)
+	})
+	t.Run("naming_convention", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		_, err = ms.AddService(&testUsersService{}, WithNamingConvention(QualifiedNameConvention))
+		require.NoError(t, err)
+		require.NotNil(t, ms.GetMethod("testUsersService.GetUsers"))
+		require.Nil(t, ms.GetMethod("GetUsers"))
+	})
+	t.Run("method_options", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		_, err = ms.AddService(&testUsersService{}, WithMethodOptions(WithTags("users")))
+		require.NoError(t, err)
+		require.True(t, ms.GetMethodDescriptor("GetUsers").HasTag("users"))
+		require.True(t, ms.GetMethodDescriptor("CreateUser").HasTag("users"))
+	})
+	t.Run("strict_signatures", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		_, err = ms.AddService(&testUsersService{}, StrictSignatures())
+		require.EqualError(t, err, "service 'testUsersService' has methods with wrong signatures:\n"+
+			"DeleteUser: wrong signature - must be func(context.Context, *<YourRequestStruct>)(*<YourResponseStruct>, error), found: func(context.Context, string) error")
+		require.Empty(t, ms.Methods())
+	})
+	t.Run("name_conflicts", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		_, err = ms.AddService(&testUsersService{prefix: "first-"})
+		require.NoError(t, err)
+		_, err = ms.AddService(&testUsersService{prefix: "second-"})
+		require.EqualError(t, err, "service 'testUsersService' has methods conflicting with registered methods: CreateUser, GetUsers")
+
+		response, err := ms.GetMethod("GetUsers")(context.Background(), testUsersRequest{Name: "jack"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"first-jack"}, response.(*testUsersResponse).Names)
+
+		_, err = ms.AddService(&testUsersService{}, WithNamingConvention(func(serviceName, methodName string) string {
+			return "Users"
+		}))
+		require.EqualError(t, err, "service 'testUsersService' has methods conflicting with registered methods: Users")
+		require.Nil(t, ms.GetMethodDescriptor("Users"))
+	})
+	t.Run("nil_service", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		_, err = ms.AddService(nil)
+		require.EqualError(t, err, "nil service provided")
+	})
+}
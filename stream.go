@@ -0,0 +1,162 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// A stream of responses, returned (as the response) by the Call method of adapters of streaming methods. Middleware
+// sees streams as regular responses, and transports (eg. the HTTP daemon) send the streamed responses to clients as
+// they are produced.
+type Stream interface {
+	// Returns the next response, or false if the stream ended (returning the error the method failed with, if any).
+	// Blocks until a response is produced, the stream ends, or the given context is done.
+	Next(ctx context.Context) (interface{}, bool, error)
+
+	// Stops the stream, cancelling the context of the streaming method.
+	Close() error
+}
+
+// Returns whether the given adapter adapts a streaming method, ie. one that returns a Stream from its Call method.
+func IsStreaming(adapter MethodAdapter) bool {
+	streaming, ok := adapter.(interface{ Streaming() bool })
+	return ok && streaming.Streaming()
+}
+
+type streamingKind int
+
+const (
+	notStreaming streamingKind = iota
+
+	// Methods streaming responses via a "send" callback: func(ctx, *Req, func(*Res) error) error
+	streamingSend
+
+	// Methods streaming responses via a channel: func(ctx, *Req) (<-chan *Res, error)
+	streamingChannel
+)
+
+// Returns the kind of streaming method the given function type is, if any.
+func streamingKindOf(t reflect.Type) streamingKind {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	isStructPtr := func(t reflect.Type) bool {
+		return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+	}
+	if t.Kind() != reflect.Func || t.IsVariadic() || t.NumIn() < 2 ||
+		!t.In(0).Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) || !isStructPtr(t.In(1)) {
+		return notStreaming
+	}
+
+	if t.NumIn() == 3 && t.NumOut() == 1 && t.Out(0) == errorType {
+		send := t.In(2)
+		if send.Kind() == reflect.Func && !send.IsVariadic() && send.NumIn() == 1 && isStructPtr(send.In(0)) &&
+			send.NumOut() == 1 && send.Out(0) == errorType {
+			return streamingSend
+		}
+	} else if t.NumIn() == 2 && t.NumOut() == 2 && t.Out(1) == errorType {
+		channel := t.Out(0)
+		if channel.Kind() == reflect.Chan && channel.ChanDir()&reflect.RecvDir != 0 && isStructPtr(channel.Elem()) {
+			return streamingChannel
+		}
+	}
+	return notStreaming
+}
+
+// Returns the arguments for calling the adapted method with the given context & request.
+func (a *methodAdapter) arguments(ctx context.Context, request interface{}) []reflect.Value {
+	ptrValue := reflect.New(a.requestType)
+	ptrValue.Elem().Set(reflect.ValueOf(request))
+	return []reflect.Value{reflect.ValueOf(ctx), ptrValue}
+}
+
+// Calls the adapted "send" callback streaming method in the background, returning a stream of the responses it sends.
+// Each send blocks until the response is received from the stream (or the stream is closed).
+func (a *methodAdapter) callWithSend(ctx context.Context, request interface{}) Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &sendStream{responses: make(chan interface{}), done: make(chan struct{}), cancel: cancel}
+
+	sendType := a.targetMethodType.In(2)
+	send := reflect.MakeFunc(sendType, func(in []reflect.Value) []reflect.Value {
+		err := reflect.Zero(sendType.Out(0))
+		select {
+		case stream.responses <- in[0].Interface():
+		case <-ctx.Done():
+			err = reflect.ValueOf(ctx.Err())
+		}
+		return []reflect.Value{err}
+	})
+
+	in := append(a.arguments(ctx, request), send)
+	go func() {
+		defer close(stream.done)
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				stream.err = errors.Errorf("streaming method panicked: %v", rvr)
+			}
+		}()
+		if returnValues := a.targetMethodValue.Call(in); !returnValues[0].IsNil() {
+			stream.err = returnValues[0].Interface().(error)
+		}
+	}()
+	return stream
+}
+
+// Calls the adapted channel streaming method, returning a stream of the responses received from its channel.
+func (a *methodAdapter) callWithChannel(ctx context.Context, request interface{}) (Stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	returnValues := a.targetMethodValue.Call(a.arguments(ctx, request))
+	if !returnValues[1].IsNil() {
+		cancel()
+		return nil, returnValues[1].Interface().(error)
+	}
+	return &channelStream{channel: returnValues[0], cancel: cancel}, nil
+}
+
+type sendStream struct {
+	responses chan interface{}
+	done      chan struct{}
+	err       error
+	cancel    context.CancelFunc
+}
+
+func (s *sendStream) Next(ctx context.Context) (interface{}, bool, error) {
+	select {
+	case response := <-s.responses:
+		return response, true, nil
+	case <-s.done:
+		return nil, false, s.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+func (s *sendStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+type channelStream struct {
+	channel reflect.Value
+	cancel  context.CancelFunc
+}
+
+func (s *channelStream) Next(ctx context.Context) (interface{}, bool, error) {
+	if s.channel.IsNil() {
+		return nil, false, nil
+	}
+	chosen, response, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: s.channel},
+	})
+	if chosen == 0 {
+		return nil, false, ctx.Err()
+	} else if !ok {
+		return nil, false, nil
+	}
+	return response.Interface(), true, nil
+}
+
+func (s *channelStream) Close() error {
+	s.cancel()
+	return nil
+}
@@ -0,0 +1,117 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type streamTestRequest struct{ Count int }
+type streamTestEvent struct{ N int }
+
+// Returns all responses of the given stream, along with the error it ended with.
+func collectStream(t *testing.T, response interface{}) ([]interface{}, error) {
+	stream, ok := response.(Stream)
+	require.True(t, ok)
+	responses := make([]interface{}, 0)
+	for {
+		response, ok, err := stream.Next(context.Background())
+		if !ok {
+			return responses, err
+		}
+		responses = append(responses, response)
+	}
+}
+
+func TestStreamingAdapter(t *testing.T) {
+	t.Run("send", func(t *testing.T) {
+		adapter := NewAdapter(func(ctx context.Context, req *streamTestRequest, send func(*streamTestEvent) error) error {
+			for i := 1; i <= req.Count; i++ {
+				if err := send(&streamTestEvent{i}); err != nil {
+					return err
+				}
+			}
+			return errors.New("done")
+		})
+		require.True(t, IsStreaming(adapter))
+		require.Equal(t, reflect.TypeOf(streamTestRequest{}), adapter.RequestType())
+		require.Equal(t, reflect.TypeOf(streamTestEvent{}), adapter.ResponseType())
+
+		response, err := adapter.Call(context.Background(), streamTestRequest{Count: 2})
+		require.NoError(t, err)
+		responses, err := collectStream(t, response)
+		require.EqualError(t, err, "done")
+		require.Equal(t, []interface{}{&streamTestEvent{1}, &streamTestEvent{2}}, responses)
+	})
+	t.Run("send_closed", func(t *testing.T) {
+		sendErr := make(chan error, 1)
+		adapter := NewAdapter(func(ctx context.Context, req *streamTestRequest, send func(*streamTestEvent) error) error {
+			err := send(&streamTestEvent{1})
+			sendErr <- err
+			return err
+		})
+		response, err := adapter.Call(context.Background(), streamTestRequest{})
+		require.NoError(t, err)
+		require.NoError(t, response.(Stream).Close())
+		select {
+		case err := <-sendErr:
+			require.Equal(t, context.Canceled, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "send was not cancelled")
+		}
+	})
+	t.Run("send_panic", func(t *testing.T) {
+		adapter := NewAdapter(func(ctx context.Context, req *streamTestRequest, send func(*streamTestEvent) error) error {
+			panic("bad")
+		})
+		response, err := adapter.Call(context.Background(), streamTestRequest{})
+		require.NoError(t, err)
+		_, err = collectStream(t, response)
+		require.EqualError(t, err, "streaming method panicked: bad")
+	})
+	t.Run("channel", func(t *testing.T) {
+		adapter := NewAdapter(func(ctx context.Context, req *streamTestRequest) (<-chan *streamTestEvent, error) {
+			if req.Count < 0 {
+				return nil, errors.New("negative count")
+			}
+			events := make(chan *streamTestEvent)
+			go func() {
+				defer close(events)
+				for i := 1; i <= req.Count; i++ {
+					select {
+					case events <- &streamTestEvent{i}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+			return events, nil
+		})
+		require.True(t, IsStreaming(adapter))
+		require.Equal(t, reflect.TypeOf(streamTestEvent{}), adapter.ResponseType())
+
+		response, err := adapter.Call(context.Background(), streamTestRequest{Count: 3})
+		require.NoError(t, err)
+		responses, err := collectStream(t, response)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{&streamTestEvent{1}, &streamTestEvent{2}, &streamTestEvent{3}}, responses)
+
+		response, err = adapter.Call(context.Background(), streamTestRequest{Count: -1})
+		require.EqualError(t, err, "negative count")
+		require.Nil(t, response)
+	})
+	t.Run("not_streaming", func(t *testing.T) {
+		require.False(t, IsStreaming(NewAdapter(func(ctx context.Context, req *streamTestRequest) (*streamTestEvent, error) {
+			return nil, nil
+		})))
+		require.Panics(t, func() {
+			NewAdapter(func(ctx context.Context, req *streamTestRequest, send func(streamTestEvent) error) error { return nil })
+		})
+		require.Panics(t, func() {
+			NewAdapter(func(ctx context.Context, req *streamTestRequest) (chan<- *streamTestEvent, error) { return nil, nil })
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+)
+
+type RestartMode int
+
+const (
+	RestartNever RestartMode = iota
+	RestartOnFailure
+	RestartAlways
+)
+
+// Determines whether & when a daemon is restarted after it exits.
+type RestartPolicy struct {
+
+	// When to restart the daemon; defaults to never.
+	Mode RestartMode
+
+	// Delay before the first restart, doubled on each consecutive restart up to MaxBackoff. The delay is reset once the
+	// daemon runs for longer than MaxBackoff. Default to 1 second & 1 minute, respectively.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Maximum number of restarts allowed within Window; once reached, the daemon is no longer restarted, and its last
+	// error is returned. Zero means unlimited restarts, and a zero window counts restarts over the daemon's lifetime.
+	MaxRestarts int
+	Window      time.Duration
+}
+
+type DaemonOption func(*daemonEntry)
+
+func WithRestartPolicy(policy RestartPolicy) DaemonOption {
+	return func(entry *daemonEntry) {
+		entry.restartPolicy = policy
+	}
+}
+
+// Declares that the daemon must only be started once the named daemons are ready.
+func DependsOn(names ...string) DaemonOption {
+	return func(entry *daemonEntry) {
+		entry.dependencies = append(entry.dependencies, names...)
+	}
+}
+
+// Assigns the daemon to the given startup phase; daemons are only started once all daemons of earlier phases are ready.
+// Daemons are in phase 0 by default.
+func InPhase(phase int) DaemonOption {
+	return func(entry *daemonEntry) {
+		entry.phase = phase
+	}
+}
+
+type daemonEntry struct {
+	daemon        Daemon
+	restartPolicy RestartPolicy
+	dependencies  []string
+	phase         int
+}
+
+type runningDaemon struct {
+	entry  *daemonEntry
+	cancel context.CancelFunc
+	err    error
+	exited bool
+
+	// Set (atomically) once the supervisor gave up on the daemon, ie. it failed and will not be restarted; unlike the
+	// daemon's status, this is not set while the daemon waits to be restarted
+	failed int32
+}
+
+// A context that carries the values of its parent, but is not cancelled when its parent is.
+type detachedContext struct {
+	context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+// Returns the registered daemons in the order they should be started: by phase, then by dependencies, and then by the
+// order in which they were added.
+func (ms *MicroService) orderDaemons() ([]*daemonEntry, error) {
+	entriesByName := make(map[string][]*daemonEntry)
+	for _, entry := range ms.daemons {
+		entriesByName[entry.daemon.Name()] = append(entriesByName[entry.daemon.Name()], entry)
+	}
+	for _, entry := range ms.daemons {
+		for _, dependency := range entry.dependencies {
+			if matches := len(entriesByName[dependency]); matches == 0 {
+				return nil, errors.Errorf("daemon '%s' depends on unknown daemon '%s'", entry.daemon.Name(), dependency)
+			} else if matches > 1 {
+				return nil, errors.Errorf("daemon '%s' depends on ambiguous daemon '%s'", entry.daemon.Name(), dependency)
+			}
+		}
+	}
+
+	ordered := make([]*daemonEntry, 0, len(ms.daemons))
+	added := make(map[*daemonEntry]bool)
+	for len(ordered) < len(ms.daemons) {
+		// Find the earliest phase that still has daemons to start
+		var next *daemonEntry
+		minPhase := 0
+		first := true
+		for _, entry := range ms.daemons {
+			if !added[entry] && (first || entry.phase < minPhase) {
+				minPhase, first = entry.phase, false
+			}
+		}
+
+		// Pick the first daemon in that phase whose dependencies have all been started
+		for _, entry := range ms.daemons {
+			if added[entry] || entry.phase != minPhase {
+				continue
+			}
+			satisfied := true
+			for _, dependency := range entry.dependencies {
+				if !added[entriesByName[dependency][0]] {
+					satisfied = false
+					break
+				}
+			}
+			if satisfied {
+				next = entry
+				break
+			}
+		}
+		if next == nil {
+			names := make([]string, 0)
+			for _, entry := range ms.daemons {
+				if !added[entry] {
+					names = append(names, entry.daemon.Name())
+				}
+			}
+			return nil, errors.Errorf("circular or unsatisfiable daemon dependencies among: %s", strings.Join(names, ", "))
+		}
+		ordered = append(ordered, next)
+		added[next] = true
+	}
+	return ordered, nil
+}
+
+// Starts supervising the given daemon in a new goroutine, sending it to the given channel once it exits.
+func (ms *MicroService) launchDaemon(ctx context.Context, entry *daemonEntry, exited chan<- *runningDaemon) *runningDaemon {
+	ctx, cancel := context.WithCancel(ctx)
+	rd := &runningDaemon{entry: entry, cancel: cancel}
+	go func() {
+		defer cancel()
+		rd.err = ms.superviseDaemon(ctx, entry)
+		if rd.err != nil {
+			atomic.StoreInt32(&rd.failed, 1)
+		}
+		exited <- rd
+	}()
+	return rd
+}
+
+// Returns the daemon restarts counter, registering it unless already registered (eg. by another micro-service in the
+// same process). The service name is only used as a label, since it may not be a valid metric name.
+func newDaemonRestartsMetric() (*prometheus.CounterVec, error) {
+	metric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "services",
+		Name:      "daemon_restarts_total",
+		Help:      "Number of times daemons were restarted by their supervisor.",
+	}, []string{"service", "daemon"})
+
+	if err := prometheus.DefaultRegisterer.Register(metric); err != nil {
+		if registered, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := registered.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, errors.Wrap(err, "failed registering daemon restarts metric")
+	}
+	return metric, nil
+}
+
+// Runs the given daemon, restarting it according to its restart policy, until it exits without being restarted or the
+// context is cancelled. Panics raised by the daemon are recovered, logged, and treated as failures.
+func (ms *MicroService) superviseDaemon(ctx context.Context, entry *daemonEntry) error {
+	policy := entry.restartPolicy
+	initialBackoff := policy.Backoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultRestartBackoff
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRestartMaxBackoff
+	}
+
+	backoff := initialBackoff
+	restarts := make([]time.Time, 0)
+	for {
+		startTime := time.Now()
+		err := ms.startDaemon(ctx, entry.daemon)
+		if ctx.Err() != nil {
+			return err
+		}
+
+		switch policy.Mode {
+		case RestartNever:
+			return err
+		case RestartOnFailure:
+			if err == nil {
+				return nil
+			}
+		}
+
+		// Enforce the restart limit
+		now := time.Now()
+		if policy.MaxRestarts > 0 {
+			if policy.Window > 0 {
+				recent := restarts[:0]
+				for _, t := range restarts {
+					if now.Sub(t) < policy.Window {
+						recent = append(recent, t)
+					}
+				}
+				restarts = recent
+			}
+			if len(restarts) >= policy.MaxRestarts {
+				ms.Log("daemon", entry.daemon.Name(), "msg", "daemon reached its restart limit, giving up")
+				return err
+			}
+		}
+		restarts = append(restarts, now)
+
+		// Wait before restarting, unless the micro-service is shutting down meanwhile
+		if now.Sub(startTime) > maxBackoff {
+			backoff = initialBackoff
+		}
+		if err != nil {
+			ms.Log("daemon", entry.daemon.Name(), "err", errors.Wrapf(err, "daemon failed, restarting in %s", backoff))
+		} else {
+			ms.Log("daemon", entry.daemon.Name(), "msg", "daemon exited, restarting in "+backoff.String())
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		ms.daemonRestarts.With(prometheus.Labels{"service": ms.name, "daemon": entry.daemon.Name()}).Inc()
+	}
+}
+
+func (ms *MicroService) startDaemon(ctx context.Context, daemon Daemon) (err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			ms.Log("daemon", daemon.Name(), "panic", rvr, "msg", "recovered from daemon panic")
+			err = errors.Errorf("daemon panicked: %v", rvr)
+		}
+	}()
+	return daemon.Start(ctx)
+}
@@ -0,0 +1,250 @@
+package msvc
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSuperviseDaemon(t *testing.T) {
+	// Restart counters are shared by all micro-services in the process, so each counting test uses its own service name
+	restartsOf := func(ms *MicroService, name string) float64 {
+		return testutil.ToFloat64(ms.daemonRestarts.With(prometheus.Labels{"service": ms.name, "daemon": name}))
+	}
+	t.Run("never", func(t *testing.T) {
+		ms, err := New("supervise-never", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return errors.New("bad")
+		}))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'd' failed: bad")
+		require.Equal(t, int32(1), atomic.LoadInt32(&runs))
+		require.Equal(t, float64(0), restartsOf(ms, "d"))
+	})
+	t.Run("on_failure", func(t *testing.T) {
+		ms, err := New("supervise-on-failure", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) < 3 {
+				return errors.New("bad")
+			}
+			return nil
+		}), WithRestartPolicy(RestartPolicy{Mode: RestartOnFailure, Backoff: time.Millisecond}))
+		require.NoError(t, ms.RunContext(context.Background()))
+		require.Equal(t, int32(3), atomic.LoadInt32(&runs))
+		require.Equal(t, float64(2), restartsOf(ms, "d"))
+	})
+	t.Run("always", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ctx, cancel := context.WithCancel(context.Background())
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 3 {
+				cancel()
+			}
+			return nil
+		}), WithRestartPolicy(RestartPolicy{Mode: RestartAlways, Backoff: time.Millisecond}))
+		require.NoError(t, ms.RunContext(ctx))
+		require.Equal(t, int32(3), atomic.LoadInt32(&runs))
+	})
+	t.Run("max_restarts", func(t *testing.T) {
+		ms, err := New("supervise-max-restarts", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return errors.New("bad")
+		}), WithRestartPolicy(RestartPolicy{
+			Mode:        RestartOnFailure,
+			Backoff:     time.Millisecond,
+			MaxRestarts: 2,
+			Window:      time.Minute,
+		}))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'd' failed: bad")
+		require.Equal(t, int32(3), atomic.LoadInt32(&runs))
+		require.Equal(t, float64(2), restartsOf(ms, "d"))
+	})
+	t.Run("panic", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 1 {
+				panic("boom")
+			}
+			return nil
+		}), WithRestartPolicy(RestartPolicy{Mode: RestartOnFailure, Backoff: time.Millisecond}))
+		require.NoError(t, ms.RunContext(context.Background()))
+		require.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	})
+	t.Run("panic_without_restart", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error { panic("boom") }))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'd' failed: daemon panicked: boom")
+	})
+	t.Run("no_restart_during_shutdown", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		var runs int32
+		ms.AddDaemon(NewDaemon("d", func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			<-ctx.Done()
+			return nil
+		}), WithRestartPolicy(RestartPolicy{Mode: RestartAlways, Backoff: time.Millisecond}))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		require.NoError(t, ms.RunContext(ctx))
+		require.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	})
+}
+
+type eventLog struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string{}, l.events...)
+}
+
+func newRecordingDaemon(name string, log *eventLog) Daemon {
+	return NewDaemon(name, func(ctx context.Context) error {
+		log.add("start:" + name)
+		<-ctx.Done()
+		log.add("stop:" + name)
+		return nil
+	})
+}
+
+// A daemon that only becomes ready after a delay.
+type slowDaemon struct {
+	Daemon
+	delay time.Duration
+	ready int32
+}
+
+func (d *slowDaemon) Start(ctx context.Context) error {
+	time.AfterFunc(d.delay, func() { atomic.StoreInt32(&d.ready, 1) })
+	return d.Daemon.Start(ctx)
+}
+
+func (d *slowDaemon) Status() DaemonStatus {
+	if atomic.LoadInt32(&d.ready) == 0 {
+		return DaemonStarting
+	}
+	return d.Daemon.Status()
+}
+
+func TestDaemonOrdering(t *testing.T) {
+	run := func(t *testing.T, ms *MicroService) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		require.NoError(t, ms.RunContext(ctx))
+	}
+	t.Run("dependencies", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		log := &eventLog{}
+		ms.AddDaemon(newRecordingDaemon("consumer", log), DependsOn("http", "cache"))
+		ms.AddDaemon(newRecordingDaemon("http", log), DependsOn("cache"))
+		ms.AddDaemon(newRecordingDaemon("cache", log))
+		run(t, ms)
+		require.Equal(t, []string{
+			"start:cache", "start:http", "start:consumer",
+			"stop:consumer", "stop:http", "stop:cache",
+		}, log.get())
+	})
+	t.Run("phases", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		log := &eventLog{}
+		ms.AddDaemon(newRecordingDaemon("late", log), InPhase(2))
+		ms.AddDaemon(newRecordingDaemon("early", log), InPhase(-1))
+		ms.AddDaemon(newRecordingDaemon("default", log))
+		run(t, ms)
+		require.Equal(t, []string{
+			"start:early", "start:default", "start:late",
+			"stop:late", "stop:default", "stop:early",
+		}, log.get())
+	})
+	t.Run("waits_for_readiness", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		log := &eventLog{}
+		ms.AddDaemon(&slowDaemon{Daemon: newRecordingDaemon("slow", log), delay: 50 * time.Millisecond})
+		ms.AddDaemon(NewDaemon("dependent", func(ctx context.Context) error {
+			log.add("start:dependent")
+			return nil
+		}), DependsOn("slow"))
+
+		begin := time.Now()
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			for len(log.get()) < 2 {
+				time.Sleep(5 * time.Millisecond)
+			}
+			cancel()
+		}()
+		require.NoError(t, ms.RunContext(ctx))
+		require.True(t, time.Since(begin) >= 50*time.Millisecond)
+		require.Equal(t, []string{"start:slow", "start:dependent", "stop:slow"}, log.get())
+	})
+	t.Run("startup_timeout", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.SetStartupTimeout(50 * time.Millisecond)
+		log := &eventLog{}
+		ms.AddDaemon(&slowDaemon{Daemon: newRecordingDaemon("slow", log), delay: time.Hour})
+		ms.AddDaemon(newRecordingDaemon("dependent", log), DependsOn("slow"))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'slow' did not become ready within 50ms")
+		require.Equal(t, []string{"start:slow", "stop:slow"}, log.get())
+	})
+	t.Run("unknown_dependency", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("a", func(ctx context.Context) error { return nil }), DependsOn("b"))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'a' depends on unknown daemon 'b'")
+	})
+	t.Run("ambiguous_dependency", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("a", func(ctx context.Context) error { return nil }), DependsOn("b"))
+		ms.AddDaemon(NewDaemon("b", func(ctx context.Context) error { return nil }))
+		ms.AddDaemon(NewDaemon("b", func(ctx context.Context) error { return nil }))
+		require.EqualError(t, ms.RunContext(context.Background()), "daemon 'a' depends on ambiguous daemon 'b'")
+	})
+	t.Run("circular_dependency", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("a", func(ctx context.Context) error { return nil }), DependsOn("b"))
+		ms.AddDaemon(NewDaemon("b", func(ctx context.Context) error { return nil }), DependsOn("a"))
+		ms.AddDaemon(NewDaemon("c", func(ctx context.Context) error { return nil }))
+		require.EqualError(t, ms.RunContext(context.Background()), "circular or unsatisfiable daemon dependencies among: a, b")
+	})
+	t.Run("dependency_in_later_phase", func(t *testing.T) {
+		ms, err := New("test", &struct{}{})
+		require.NoError(t, err)
+		ms.AddDaemon(NewDaemon("a", func(ctx context.Context) error { return nil }), DependsOn("b"))
+		ms.AddDaemon(NewDaemon("b", func(ctx context.Context) error { return nil }), InPhase(1))
+		require.EqualError(t, ms.RunContext(context.Background()), "circular or unsatisfiable daemon dependencies among: a, b")
+	})
+}